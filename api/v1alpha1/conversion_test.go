@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+// fuzzSeeds is the number of seeds each conversion is checked with by go
+// test. Run go test -fuzz to explore further.
+const fuzzSeeds = 200
+
+// newFuzzer returns a fuzzer producing objects that the API server would
+// accept, as conversion only needs to be lossless for those.
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0.2).NumElements(0, 3).Funcs(
+		// The type is set by the caller of a conversion rather than by it.
+		func(*metav1.TypeMeta, fuzz.Continue) {},
+		func(q *resource.Quantity, c fuzz.Continue) {
+			*q = *resource.NewQuantity(c.Int63n(1<<20), resource.DecimalSI)
+		},
+		func(spec *VirtoolAppSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			for i := range spec.Components {
+				// Component names are unique and v1alpha1 requires an image.
+				spec.Components[i].Name = fmt.Sprintf("component-%d", i)
+				if spec.Components[i].Image == "" {
+					spec.Components[i].Image = "ghcr.io/virtool/virtool"
+				}
+			}
+		},
+		func(spec *v1beta1.VirtoolAppSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			for i := range spec.Components {
+				spec.Components[i].Name = fmt.Sprintf("component-%d", i)
+				// Leave some components to use the image for their role.
+				if c.RandBool() {
+					spec.Components[i].Image = ""
+					spec.Components[i].Role = v1beta1.ComponentRoleAPI
+				}
+			}
+		},
+	)
+}
+
+// checkRoundTrips converts a fuzzed spoke to the hub and back, and a fuzzed
+// hub to the spoke and back, failing if either changes the object.
+func checkRoundTrips(t *testing.T, seed int64, newSpoke func() conversion.Convertible, newHub func() conversion.Hub) {
+	f := newFuzzer(seed)
+
+	spoke := newSpoke()
+	f.Fuzz(spoke)
+	hub := newHub()
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatalf("converting to the hub: %v", err)
+	}
+	got := newSpoke()
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("converting from the hub: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(spoke, got) {
+		t.Errorf("spoke changed by a round trip through the hub:\nwant %+v\ngot  %+v", spoke, got)
+	}
+
+	hub = newHub()
+	f.Fuzz(hub)
+	spoke = newSpoke()
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("converting from the hub: %v", err)
+	}
+	gotHub := newHub()
+	if err := spoke.ConvertTo(gotHub); err != nil {
+		t.Fatalf("converting to the hub: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(hub, gotHub) {
+		t.Errorf("hub changed by a round trip through the spoke:\nwant %+v\ngot  %+v", hub, gotHub)
+	}
+}
+
+func FuzzVirtoolAppConversion(f *testing.F) {
+	for seed := int64(0); seed < fuzzSeeds; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		checkRoundTrips(t, seed,
+			func() conversion.Convertible { return &VirtoolApp{} },
+			func() conversion.Hub { return &v1beta1.VirtoolApp{} },
+		)
+	})
+}
+
+func FuzzVirtoolBackupConversion(f *testing.F) {
+	for seed := int64(0); seed < fuzzSeeds; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		checkRoundTrips(t, seed,
+			func() conversion.Convertible { return &VirtoolBackup{} },
+			func() conversion.Hub { return &v1beta1.VirtoolBackup{} },
+		)
+	})
+}
+
+func FuzzVirtoolRestoreConversion(f *testing.F) {
+	for seed := int64(0); seed < fuzzSeeds; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		checkRoundTrips(t, seed,
+			func() conversion.Convertible { return &VirtoolRestore{} },
+			func() conversion.Hub { return &v1beta1.VirtoolRestore{} },
+		)
+	})
+}
+
+func TestConvertFromRoleImages(t *testing.T) {
+	hub := &v1beta1.VirtoolApp{Spec: v1beta1.VirtoolAppSpec{
+		Version: "7.1.0",
+		Image:   v1beta1.ImageSpec{Repository: "registry.example.com/virtool"},
+		Components: []v1beta1.ComponentSpec{
+			{Name: "api", Role: v1beta1.ComponentRoleAPI},
+			{Name: "ui", Role: v1beta1.ComponentRoleUI},
+			{Name: "runner", Role: v1beta1.ComponentRoleWorkflowRunner, Image: "ghcr.io/virtool/workflow-nuvs"},
+		},
+	}}
+
+	var spoke VirtoolApp
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"api":    "registry.example.com/virtool/virtool",
+		"ui":     "registry.example.com/virtool/ui",
+		"runner": "ghcr.io/virtool/workflow-nuvs",
+	}
+	for _, component := range spoke.Spec.Components {
+		if component.Image != want[component.Name] {
+			t.Errorf("%s: got image %q, want %q", component.Name, component.Image, want[component.Name])
+		}
+	}
+
+	// An image changed through v1alpha1 is kept rather than reset to the
+	// image for the role.
+	spoke.Spec.Components[1].Image = "registry.example.com/virtool/ui:7.0.2"
+
+	var got v1beta1.VirtoolApp
+	if err := spoke.ConvertTo(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.Image.Repository != "registry.example.com/virtool" {
+		t.Errorf("got repository %q, want it restored", got.Spec.Image.Repository)
+	}
+	if got.Spec.Components[0].Image != "" {
+		t.Errorf("got api image %q, want it to use the image for its role", got.Spec.Components[0].Image)
+	}
+	if got.Spec.Components[1].Image != "registry.example.com/virtool/ui:7.0.2" {
+		t.Errorf("got ui image %q, want the image set through v1alpha1", got.Spec.Components[1].Image)
+	}
+	if _, ok := got.Annotations[conversionAnnotation]; ok {
+		t.Error("expected the conversion annotation to be removed")
+	}
+}
@@ -0,0 +1,419 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+// conversionAnnotation holds the parts of a v1beta1 VirtoolApp that v1alpha1
+// cannot represent, so that converting back to v1beta1 restores them.
+const conversionAnnotation = "virtool.virtool.ca/v1beta1-conversion"
+
+// conversionData is stored in the conversion annotation.
+type conversionData struct {
+	// ImageRepository is the image repository of the app
+	ImageRepository string `json:"imageRepository,omitempty"`
+
+	// RoleImages are the names of the components that use the image for
+	// their role rather than one of their own
+	RoleImages []string `json:"roleImages,omitempty"`
+}
+
+// ConvertTo converts this VirtoolApp to the hub version.
+func (src *VirtoolApp) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.VirtoolApp)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolApp but got %T", dstRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec = v1beta1.VirtoolAppSpec{
+		Version:         src.Spec.Version,
+		Dependencies:    dependenciesToHub(src.Spec.Dependencies),
+		DriftPolicy:     v1beta1.DriftPolicy(src.Spec.DriftPolicy),
+		PinImageDigests: src.Spec.PinImageDigests,
+		Strategy: v1beta1.UpgradeStrategy{
+			Backup:  backupPolicyToHub(src.Spec.Backup),
+			Channel: channelToHub(src.Spec.Channel),
+		},
+	}
+	if src.Spec.Components != nil {
+		dst.Spec.Components = make([]v1beta1.ComponentSpec, len(src.Spec.Components))
+	}
+	for i := range src.Spec.Components {
+		dst.Spec.Components[i] = componentToHub(&src.Spec.Components[i])
+	}
+
+	if err := restoreConversionData(dst); err != nil {
+		return err
+	}
+
+	dst.Status = appStatusToHub(&src.Status)
+
+	return nil
+}
+
+// ConvertFrom converts the hub version to this VirtoolApp. Components that
+// use the image for their role are given that image explicitly, as v1alpha1
+// requires one.
+func (dst *VirtoolApp) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.VirtoolApp)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolApp but got %T", srcRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	data := conversionData{ImageRepository: src.Spec.Image.Repository}
+
+	dst.Spec = VirtoolAppSpec{
+		Version:         src.Spec.Version,
+		Dependencies:    dependenciesFromHub(src.Spec.Dependencies),
+		Backup:          backupPolicyFromHub(src.Spec.Strategy.Backup),
+		Channel:         channelFromHub(src.Spec.Strategy.Channel),
+		DriftPolicy:     DriftPolicy(src.Spec.DriftPolicy),
+		PinImageDigests: src.Spec.PinImageDigests,
+	}
+	if src.Spec.Components != nil {
+		dst.Spec.Components = make([]ComponentSpec, len(src.Spec.Components))
+	}
+	for i := range src.Spec.Components {
+		component := &src.Spec.Components[i]
+		dst.Spec.Components[i] = componentFromHub(component)
+
+		if component.Image == "" {
+			if image := src.Spec.ComponentImage(component); image != "" {
+				dst.Spec.Components[i].Image = image
+				data.RoleImages = append(data.RoleImages, component.Name)
+			}
+		}
+	}
+
+	if data.ImageRepository != "" || len(data.RoleImages) > 0 {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("encoding conversion data: %w", err)
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[conversionAnnotation] = string(raw)
+	}
+
+	dst.Status = appStatusFromHub(&src.Status)
+
+	return nil
+}
+
+// restoreConversionData restores the parts of app recorded in its conversion
+// annotation and removes the annotation. Components are only returned to the
+// image of their role if their image was not changed since.
+func restoreConversionData(app *v1beta1.VirtoolApp) error {
+	raw, ok := app.Annotations[conversionAnnotation]
+	if !ok {
+		return nil
+	}
+
+	delete(app.Annotations, conversionAnnotation)
+	if len(app.Annotations) == 0 {
+		app.Annotations = nil
+	}
+
+	var data conversionData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return fmt.Errorf("decoding conversion data: %w", err)
+	}
+
+	app.Spec.Image.Repository = data.ImageRepository
+
+	roleImages := make(map[string]bool, len(data.RoleImages))
+	for _, name := range data.RoleImages {
+		roleImages[name] = true
+	}
+
+	for i := range app.Spec.Components {
+		component := &app.Spec.Components[i]
+		if !roleImages[component.Name] {
+			continue
+		}
+
+		image := component.Image
+		component.Image = ""
+		if app.Spec.ComponentImage(component) != image {
+			component.Image = image
+		}
+	}
+
+	return nil
+}
+
+func componentToHub(src *ComponentSpec) v1beta1.ComponentSpec {
+	return v1beta1.ComponentSpec{
+		Name:             src.Name,
+		Role:             v1beta1.ComponentRole(src.Role),
+		Image:            src.Image,
+		Command:          src.Command,
+		Args:             src.Args,
+		Env:              src.Env,
+		Replicas:         src.Replicas,
+		Port:             src.Port,
+		Probes:           probesToHub(src.Probes),
+		Resources:        src.Resources,
+		PreUpdateJob:     (*v1beta1.JobSpec)(src.PreUpdateJob),
+		PostUpdateJob:    (*v1beta1.JobSpec)(src.PostUpdateJob),
+		DisruptionBudget: (*v1beta1.DisruptionBudgetSpec)(src.DisruptionBudget),
+		Adopt:            (*v1beta1.AdoptSpec)(src.Adopt),
+	}
+}
+
+func componentFromHub(src *v1beta1.ComponentSpec) ComponentSpec {
+	return ComponentSpec{
+		Name:             src.Name,
+		Role:             ComponentRole(src.Role),
+		Image:            src.Image,
+		Command:          src.Command,
+		Args:             src.Args,
+		Env:              src.Env,
+		Replicas:         src.Replicas,
+		Port:             src.Port,
+		Probes:           probesFromHub(src.Probes),
+		Resources:        src.Resources,
+		PreUpdateJob:     (*JobSpec)(src.PreUpdateJob),
+		PostUpdateJob:    (*JobSpec)(src.PostUpdateJob),
+		DisruptionBudget: (*DisruptionBudgetSpec)(src.DisruptionBudget),
+		Adopt:            (*AdoptSpec)(src.Adopt),
+	}
+}
+
+func probesToHub(src *ProbesSpec) *v1beta1.ProbesSpec {
+	if src == nil {
+		return nil
+	}
+	dst := &v1beta1.ProbesSpec{
+		Liveness:  src.Liveness,
+		Readiness: src.Readiness,
+		Startup:   src.Startup,
+	}
+	for _, probe := range src.Disabled {
+		dst.Disabled = append(dst.Disabled, v1beta1.ProbeType(probe))
+	}
+	return dst
+}
+
+func probesFromHub(src *v1beta1.ProbesSpec) *ProbesSpec {
+	if src == nil {
+		return nil
+	}
+	dst := &ProbesSpec{
+		Liveness:  src.Liveness,
+		Readiness: src.Readiness,
+		Startup:   src.Startup,
+	}
+	for _, probe := range src.Disabled {
+		dst.Disabled = append(dst.Disabled, ProbeType(probe))
+	}
+	return dst
+}
+
+func dependenciesToHub(src *DependenciesSpec) *v1beta1.DependenciesSpec {
+	if src == nil {
+		return nil
+	}
+	return &v1beta1.DependenciesSpec{
+		Postgres: dependencyToHub(src.Postgres),
+		MongoDB:  dependencyToHub(src.MongoDB),
+		Redis:    dependencyToHub(src.Redis),
+	}
+}
+
+func dependenciesFromHub(src *v1beta1.DependenciesSpec) *DependenciesSpec {
+	if src == nil {
+		return nil
+	}
+	return &DependenciesSpec{
+		Postgres: dependencyFromHub(src.Postgres),
+		MongoDB:  dependencyFromHub(src.MongoDB),
+		Redis:    dependencyFromHub(src.Redis),
+	}
+}
+
+func dependencyToHub(src *DependencySpec) *v1beta1.DependencySpec {
+	if src == nil {
+		return nil
+	}
+	return &v1beta1.DependencySpec{
+		Managed:  (*v1beta1.ManagedDependencySpec)(src.Managed),
+		External: (*v1beta1.ExternalDependencySpec)(src.External),
+	}
+}
+
+func dependencyFromHub(src *v1beta1.DependencySpec) *DependencySpec {
+	if src == nil {
+		return nil
+	}
+	return &DependencySpec{
+		Managed:  (*ManagedDependencySpec)(src.Managed),
+		External: (*ExternalDependencySpec)(src.External),
+	}
+}
+
+func backupPolicyToHub(src *BackupPolicy) *v1beta1.BackupPolicy {
+	if src == nil {
+		return nil
+	}
+	return &v1beta1.BackupPolicy{
+		Target: v1beta1.BackupTarget{
+			PersistentVolumeClaim: (*v1beta1.PersistentVolumeClaimTarget)(src.Target.PersistentVolumeClaim),
+			ObjectStorage:         (*v1beta1.ObjectStorageTarget)(src.Target.ObjectStorage),
+		},
+		PostgresImage: src.PostgresImage,
+		MongoDBImage:  src.MongoDBImage,
+	}
+}
+
+func backupPolicyFromHub(src *v1beta1.BackupPolicy) *BackupPolicy {
+	if src == nil {
+		return nil
+	}
+	return &BackupPolicy{
+		Target: BackupTarget{
+			PersistentVolumeClaim: (*PersistentVolumeClaimTarget)(src.Target.PersistentVolumeClaim),
+			ObjectStorage:         (*ObjectStorageTarget)(src.Target.ObjectStorage),
+		},
+		PostgresImage: src.PostgresImage,
+		MongoDBImage:  src.MongoDBImage,
+	}
+}
+
+func channelToHub(src *ChannelSpec) *v1beta1.ChannelSpec {
+	if src == nil {
+		return nil
+	}
+	return &v1beta1.ChannelSpec{
+		VersionConstraint: src.VersionConstraint,
+		Source:            v1beta1.ReleaseSource(src.Source),
+		Repository:        src.Repository,
+		URL:               src.URL,
+		AutoUpdate:        src.AutoUpdate,
+		PollInterval:      src.PollInterval,
+	}
+}
+
+func channelFromHub(src *v1beta1.ChannelSpec) *ChannelSpec {
+	if src == nil {
+		return nil
+	}
+	return &ChannelSpec{
+		VersionConstraint: src.VersionConstraint,
+		Source:            ReleaseSource(src.Source),
+		Repository:        src.Repository,
+		URL:               src.URL,
+		AutoUpdate:        src.AutoUpdate,
+		PollInterval:      src.PollInterval,
+	}
+}
+
+func appStatusToHub(src *VirtoolAppStatus) v1beta1.VirtoolAppStatus {
+	dst := v1beta1.VirtoolAppStatus{
+		CurrentVersion:       src.CurrentVersion,
+		ObservedGeneration:   src.ObservedGeneration,
+		TargetVersion:        src.TargetVersion,
+		UpgradeStage:         v1beta1.UpgradeStage(src.UpgradeStage),
+		UpgradePath:          src.UpgradePath,
+		CompletedHops:        src.CompletedHops,
+		UpgradeID:            src.UpgradeID,
+		UpgradeTraceParent:   src.UpgradeTraceParent,
+		AvailableVersion:     src.AvailableVersion,
+		LastReleaseCheckTime: src.LastReleaseCheckTime,
+		ReadyComponents:      src.ReadyComponents,
+		Conditions:           src.Conditions,
+	}
+	if src.ComponentsStatus != nil {
+		dst.ComponentsStatus = make([]v1beta1.ComponentStatus, len(src.ComponentsStatus))
+		for i := range src.ComponentsStatus {
+			dst.ComponentsStatus[i] = v1beta1.ComponentStatus(src.ComponentsStatus[i])
+		}
+	}
+	if src.History != nil {
+		dst.History = make([]v1beta1.UpgradeRecord, len(src.History))
+		for i := range src.History {
+			dst.History[i] = v1beta1.UpgradeRecord(src.History[i])
+		}
+	}
+	if src.Drift != nil {
+		dst.Drift = make([]v1beta1.DriftRecord, len(src.Drift))
+		for i := range src.Drift {
+			dst.Drift[i] = v1beta1.DriftRecord(src.Drift[i])
+		}
+	}
+	if src.ImageDigests != nil {
+		dst.ImageDigests = make([]v1beta1.ImageDigest, len(src.ImageDigests))
+		for i := range src.ImageDigests {
+			dst.ImageDigests[i] = v1beta1.ImageDigest(src.ImageDigests[i])
+		}
+	}
+	return dst
+}
+
+func appStatusFromHub(src *v1beta1.VirtoolAppStatus) VirtoolAppStatus {
+	dst := VirtoolAppStatus{
+		CurrentVersion:       src.CurrentVersion,
+		ObservedGeneration:   src.ObservedGeneration,
+		TargetVersion:        src.TargetVersion,
+		UpgradeStage:         UpgradeStage(src.UpgradeStage),
+		UpgradePath:          src.UpgradePath,
+		CompletedHops:        src.CompletedHops,
+		UpgradeID:            src.UpgradeID,
+		UpgradeTraceParent:   src.UpgradeTraceParent,
+		AvailableVersion:     src.AvailableVersion,
+		LastReleaseCheckTime: src.LastReleaseCheckTime,
+		ReadyComponents:      src.ReadyComponents,
+		Conditions:           src.Conditions,
+	}
+	if src.ComponentsStatus != nil {
+		dst.ComponentsStatus = make([]ComponentStatus, len(src.ComponentsStatus))
+		for i := range src.ComponentsStatus {
+			dst.ComponentsStatus[i] = ComponentStatus(src.ComponentsStatus[i])
+		}
+	}
+	if src.History != nil {
+		dst.History = make([]UpgradeRecord, len(src.History))
+		for i := range src.History {
+			dst.History[i] = UpgradeRecord(src.History[i])
+		}
+	}
+	if src.Drift != nil {
+		dst.Drift = make([]DriftRecord, len(src.Drift))
+		for i := range src.Drift {
+			dst.Drift[i] = DriftRecord(src.Drift[i])
+		}
+	}
+	if src.ImageDigests != nil {
+		dst.ImageDigests = make([]ImageDigest, len(src.ImageDigests))
+		for i := range src.ImageDigests {
+			dst.ImageDigests[i] = ImageDigest(src.ImageDigests[i])
+		}
+	}
+	return dst
+}
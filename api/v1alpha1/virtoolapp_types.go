@@ -18,29 +18,251 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // VirtoolAppSpec defines the desired state of the application
 type VirtoolAppSpec struct {
 	// Version is the desired version of the application
+	// +kubebuilder:validation:Pattern=`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`
 	Version string `json:"version"`
 
 	// Components is a list of components for the application
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:XValidation:rule="self.all(c, self.exists_one(o, o.name == c.name))",message="component names must be unique"
 	Components []ComponentSpec `json:"components"`
+
+	// Dependencies configures the datastores Virtool connects to. Datastores
+	// that are not configured are read from the <name>-connections secret.
+	Dependencies *DependenciesSpec `json:"dependencies,omitempty"`
+
+	// Backup configures the backups taken before each version change. No
+	// backups are taken when it is not set.
+	Backup *BackupPolicy `json:"backup,omitempty"`
+
+	// Channel tracks new Virtool releases matching a version constraint
+	Channel *ChannelSpec `json:"channel,omitempty"`
+
+	// DriftPolicy decides what the operator does when objects it manages are
+	// changed by someone else. Correct sets them back to their desired state.
+	// ReportOnly leaves the changed fields as they are and records them in
+	// the status, so that manual hotfixes can be investigated before they are
+	// overwritten. Upgrades do not roll out while fields of the components'
+	// pod templates are left.
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// PinImageDigests resolves the image tag of each component and update
+	// job to the digest it points to when it is first run at a version, and
+	// runs it from that digest. Tags that are pushed again later are reported
+	// rather than rolled out.
+	PinImageDigests bool `json:"pinImageDigests,omitempty"`
+}
+
+// ReleaseSource selects where new Virtool releases are looked up
+// +kubebuilder:validation:Enum=GitHub;Registry
+type ReleaseSource string
+
+const (
+	// ReleaseSourceGitHub looks up the releases of a GitHub repository
+	ReleaseSourceGitHub ReleaseSource = "GitHub"
+
+	// ReleaseSourceRegistry looks up the tags of a container image repository
+	ReleaseSourceRegistry ReleaseSource = "Registry"
+)
+
+// ChannelSpec defines how the operator tracks new Virtool releases
+type ChannelSpec struct {
+	// VersionConstraint selects the releases to track, for example "~7.2" for
+	// patch releases of 7.2 or "^7" for minor and patch releases of 7
+	// +kubebuilder:validation:MinLength=1
+	VersionConstraint string `json:"versionConstraint"`
+
+	// Source is where releases are looked up
+	// +kubebuilder:default=GitHub
+	Source ReleaseSource `json:"source,omitempty"`
+
+	// Repository is the GitHub repository in owner/name form or the image
+	// repository, such as ghcr.io/virtool/virtool, releases are looked up in.
+	// Defaults to the repository of the Virtool server.
+	Repository string `json:"repository,omitempty"`
+
+	// URL overrides the base URL of the GitHub API or container registry, for
+	// example to use a mirror
+	URL string `json:"url,omitempty"`
+
+	// AutoUpdate sets Version to the newest matching release once no upgrade
+	// is in progress. The upgrade then goes through the usual backups, update
+	// jobs and readiness checks.
+	AutoUpdate bool `json:"autoUpdate,omitempty"`
+
+	// PollInterval is how often releases are looked up. Defaults to one hour.
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// BackupPolicy defines the database backups taken before the operator changes
+// the version of the application. The upgrade is blocked if a backup fails.
+type BackupPolicy struct {
+	// Target is where backups are written
+	Target BackupTarget `json:"target"`
+
+	// PostgresImage overrides the image providing pg_dump
+	PostgresImage string `json:"postgresImage,omitempty"`
+
+	// MongoDBImage overrides the image providing mongodump
+	MongoDBImage string `json:"mongodbImage,omitempty"`
+}
+
+// BackupTarget selects where backups are written. Exactly one of
+// PersistentVolumeClaim and ObjectStorage should be set.
+// +kubebuilder:validation:XValidation:rule="has(self.persistentVolumeClaim) != has(self.objectStorage)",message="exactly one of persistentVolumeClaim and objectStorage must be set"
+type BackupTarget struct {
+	// PersistentVolumeClaim writes each backup to a directory named after its
+	// identifier on an existing claim
+	PersistentVolumeClaim *PersistentVolumeClaimTarget `json:"persistentVolumeClaim,omitempty"`
+
+	// ObjectStorage uploads each backup to an S3-compatible bucket
+	ObjectStorage *ObjectStorageTarget `json:"objectStorage,omitempty"`
+}
+
+// PersistentVolumeClaimTarget writes backups to a persistent volume claim
+type PersistentVolumeClaimTarget struct {
+	// ClaimName is the name of a claim in the application's namespace
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+}
+
+// ObjectStorageTarget uploads backups to an S3-compatible bucket
+type ObjectStorageTarget struct {
+	// URL is the bucket and optional prefix backups are uploaded under, for
+	// example s3://virtool-backups/production
+	// +kubebuilder:validation:Pattern=`^s3://.+`
+	URL string `json:"url"`
+
+	// Endpoint is the URL of an S3-compatible service other than AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// CredentialsSecret is a secret whose keys are exposed to the upload as
+	// environment variables, such as AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	CredentialsSecret corev1.LocalObjectReference `json:"credentialsSecret"`
+
+	// Image overrides the image providing the AWS CLI used for the upload
+	Image string `json:"image,omitempty"`
+}
+
+// DependenciesSpec defines the datastores used by the application
+type DependenciesSpec struct {
+	// Postgres configures the PostgreSQL database
+	Postgres *DependencySpec `json:"postgres,omitempty"`
+
+	// MongoDB configures the MongoDB database
+	MongoDB *DependencySpec `json:"mongodb,omitempty"`
+
+	// Redis configures the Redis cache
+	Redis *DependencySpec `json:"redis,omitempty"`
 }
 
+// DependencySpec selects how a datastore is provided. Exactly one of Managed
+// and External should be set.
+// +kubebuilder:validation:XValidation:rule="has(self.managed) != has(self.external)",message="exactly one of managed and external must be set"
+type DependencySpec struct {
+	// Managed provisions a single-instance datastore owned by the application
+	Managed *ManagedDependencySpec `json:"managed,omitempty"`
+
+	// External uses an externally managed datastore
+	External *ExternalDependencySpec `json:"external,omitempty"`
+}
+
+// ManagedDependencySpec defines a datastore provisioned by the operator as a
+// single-instance StatefulSet with generated credentials
+type ManagedDependencySpec struct {
+	// Image overrides the default image for the datastore
+	Image string `json:"image,omitempty"`
+
+	// StorageSize is the size of the datastore's persistent volume
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+
+	// StorageClassName is the storage class of the datastore's persistent volume
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="storageClassName is immutable"
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Resources defines the resource requirements for the datastore
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ExternalDependencySpec defines a datastore managed outside of the operator
+type ExternalDependencySpec struct {
+	// ConnectionSecret selects the secret key holding the connection string
+	ConnectionSecret corev1.SecretKeySelector `json:"connectionSecret"`
+}
+
+// ComponentRole selects a built-in Virtool template for a component
+// +kubebuilder:validation:Enum=api;ui;jobs-api;tasks;workflow-runner;migration
+type ComponentRole string
+
+const (
+	// ComponentRoleAPI serves the Virtool API
+	ComponentRoleAPI ComponentRole = "api"
+
+	// ComponentRoleUI serves the Virtool web client
+	ComponentRoleUI ComponentRole = "ui"
+
+	// ComponentRoleJobsAPI serves the API used by workflow runners
+	ComponentRoleJobsAPI ComponentRole = "jobs-api"
+
+	// ComponentRoleTasks runs Virtool background tasks
+	ComponentRoleTasks ComponentRole = "tasks"
+
+	// ComponentRoleWorkflowRunner runs Virtool analysis workflows
+	ComponentRoleWorkflowRunner ComponentRole = "workflow-runner"
+
+	// ComponentRoleMigration applies database migrations before each update.
+	// It runs as a pre-update job rather than a long-running workload.
+	ComponentRoleMigration ComponentRole = "migration"
+)
+
 // ComponentSpec defines the specification for a single component
 type ComponentSpec struct {
-	// Name is the name of the component
+	// Name is the name of the component. The names of the managed
+	// dependencies are reserved since their objects share the app's prefix.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:XValidation:rule="!(self in ['postgres', 'mongodb', 'redis'])",message="postgres, mongodb and redis are reserved for dependencies"
 	Name string `json:"name"`
 
+	// Role selects the built-in template used to fill in the command, args,
+	// port, probes and environment of the component. Fields set on the
+	// component take precedence over the template.
+	Role ComponentRole `json:"role,omitempty"`
+
 	// Image is the container image for the component
 	Image string `json:"image"`
 
+	// Command overrides the entrypoint of the component's container
+	Command []string `json:"command,omitempty"`
+
+	// Args overrides the arguments of the component's container
+	Args []string `json:"args,omitempty"`
+
+	// Env is merged with the environment provided by the role template.
+	// Variables set here replace template variables with the same name.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
 	// Replicas is the desired number of replicas for the component
+	// +kubebuilder:validation:Minimum=0
 	Replicas int32 `json:"replicas,omitempty"`
 
+	// Port is the container port the component serves HTTP on, if any
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// Probes overrides the default health probes for the component
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
 	// Resources defines the resource requirements for the component
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
@@ -49,25 +271,267 @@ type ComponentSpec struct {
 
 	// PostUpdateJob defines a job to run after updating this component
 	PostUpdateJob *JobSpec `json:"postUpdateJob,omitempty"`
+
+	// DisruptionBudget defines a PodDisruptionBudget to create for this component
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+
+	// Adopt takes over an existing Deployment, and optionally Service, of the
+	// component instead of creating new ones
+	Adopt *AdoptSpec `json:"adopt,omitempty"`
+}
+
+// DisruptionBudgetSpec defines the voluntary disruption budget for a component.
+// Only one of MinAvailable and MaxUnavailable may be set.
+// +kubebuilder:validation:XValidation:rule="!(has(self.minAvailable) && has(self.maxUnavailable))",message="only one of minAvailable and maxUnavailable may be set"
+type DisruptionBudgetSpec struct {
+	// MinAvailable is the number or percentage of pods that must remain available
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of pods that may be unavailable
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// AdoptSpec selects the existing objects a component takes over. The pods of
+// an adopted Deployment keep running: its pod template is left as it is until
+// the app moves to another version.
+// +kubebuilder:validation:XValidation:rule="has(self.deploymentName) != has(self.selector)",message="exactly one of deploymentName and selector must be set"
+type AdoptSpec struct {
+	// DeploymentName is the name of the Deployment to adopt
+	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// ServiceName is the name of the Service to adopt, if any
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Selector selects the Deployment, and the Service if any, to adopt by
+	// their labels. It must match exactly one Deployment and at most one
+	// Service.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
+// ProbesSpec defines the health probes for a component's container. Probes
+// that are not set fall back to the defaults of the component's role, unless
+// they are disabled.
+// +kubebuilder:validation:XValidation:rule="!has(self.disabled) || !((has(self.liveness) && 'Liveness' in self.disabled) || (has(self.readiness) && 'Readiness' in self.disabled) || (has(self.startup) && 'Startup' in self.disabled))",message="a probe cannot be both set and disabled"
+type ProbesSpec struct {
+	// Liveness is the probe used to decide when to restart the container
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+
+	// Readiness is the probe used to decide when the container can serve
+	// traffic. Upgrades only advance once every pod of a component is ready.
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+
+	// Startup is the probe that must succeed before the other probes run
+	Startup *corev1.Probe `json:"startup,omitempty"`
+
+	// Disabled lists the probes that are not run at all instead of falling
+	// back to their defaults
+	// +listType=set
+	// +kubebuilder:validation:MaxItems=3
+	Disabled []ProbeType `json:"disabled,omitempty"`
+}
+
+// ProbeType is one of the health probes of a container
+// +kubebuilder:validation:Enum=Liveness;Readiness;Startup
+type ProbeType string
+
+const (
+	// ProbeLiveness is the probe that decides when to restart a container
+	ProbeLiveness ProbeType = "Liveness"
+
+	// ProbeReadiness is the probe that decides when a container is ready
+	ProbeReadiness ProbeType = "Readiness"
+
+	// ProbeStartup is the probe that gates the other probes
+	ProbeStartup ProbeType = "Startup"
+)
+
 // JobSpec defines a job to be run as part of the update process
 type JobSpec struct {
-	Image   string   `json:"image"`
-	Command []string `json:"command,omitempty"`
-	Args    []string `json:"args,omitempty"`
+	// +kubebuilder:validation:MinLength=1
+	Image   string          `json:"image"`
+	Command []string        `json:"command,omitempty"`
+	Args    []string        `json:"args,omitempty"`
+	Env     []corev1.EnvVar `json:"env,omitempty"`
 }
 
+// UpgradeStage is a stage of moving a VirtoolApp from one version to another
+type UpgradeStage string
+
+const (
+	// UpgradeStageBackup backs up the databases before anything is changed
+	UpgradeStageBackup UpgradeStage = "Backup"
+
+	// UpgradeStagePreUpdate runs the pre-update jobs of every component
+	UpgradeStagePreUpdate UpgradeStage = "PreUpdate"
+
+	// UpgradeStageRollout rolls every component out at the target version
+	UpgradeStageRollout UpgradeStage = "Rollout"
+
+	// UpgradeStagePostUpdate runs the post-update jobs of every component
+	UpgradeStagePostUpdate UpgradeStage = "PostUpdate"
+)
+
+const (
+	// ConditionReady indicates that every component is running and ready
+	ConditionReady = "Ready"
+
+	// ConditionUpgrading indicates that an upgrade is in progress
+	ConditionUpgrading = "Upgrading"
+
+	// ConditionUpdateAvailable indicates that the release channel has a
+	// release newer than the desired version
+	ConditionUpdateAvailable = "UpdateAvailable"
+
+	// ConditionDependenciesReady indicates that every datastore the app uses
+	// is reachable
+	ConditionDependenciesReady = "DependenciesReady"
+
+	// ConditionVersionSkew indicates that the pods of a component have run
+	// more than one version for longer than a rollout is expected to take
+	ConditionVersionSkew = "VersionSkew"
+)
+
+const (
+	// ComponentStatusReady means all replicas of a component are updated and ready
+	ComponentStatusReady = "Ready"
+
+	// ComponentStatusProgressing means a component is still rolling out
+	ComponentStatusProgressing = "Progressing"
+)
+
 // VirtoolAppStatus defines the observed state of the application
 type VirtoolAppStatus struct {
 	// CurrentVersion is the current version of the application
 	CurrentVersion string `json:"currentVersion"`
 
+	// ObservedGeneration is the generation of the spec most recently reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// TargetVersion is the version an in-progress upgrade is moving to
+	TargetVersion string `json:"targetVersion,omitempty"`
+
+	// UpgradeStage is the stage an in-progress upgrade has reached
+	UpgradeStage UpgradeStage `json:"upgradeStage,omitempty"`
+
+	// UpgradePath lists the versions an in-progress upgrade moves through in
+	// turn, ending with the desired version. Intermediate versions are the
+	// releases listed in the compatibility manifest and the versions they
+	// must be upgraded from.
+	UpgradePath []string `json:"upgradePath,omitempty"`
+
+	// CompletedHops is the number of versions in UpgradePath the application
+	// has been upgraded to
+	CompletedHops int32 `json:"completedHops,omitempty"`
+
+	// UpgradeID identifies an in-progress upgrade attempt in the logs of the
+	// operator. A new ID is assigned whenever the upgrade is planned anew.
+	UpgradeID string `json:"upgradeID,omitempty"`
+
+	// UpgradeTraceParent is the W3C traceparent of the trace of an in-progress
+	// upgrade. Reconciles during the upgrade are traced as part of it, so each
+	// upgrade attempt forms a single trace across requeues and restarts.
+	UpgradeTraceParent string `json:"upgradeTraceParent,omitempty"`
+
+	// AvailableVersion is the newest release matching the release channel
+	AvailableVersion string `json:"availableVersion,omitempty"`
+
+	// LastReleaseCheckTime is when the release channel was last looked up
+	LastReleaseCheckTime *metav1.Time `json:"lastReleaseCheckTime,omitempty"`
+
+	// ReadyComponents is the number of ready components out of the total,
+	// such as 4/5
+	ReadyComponents string `json:"readyComponents,omitempty"`
+
 	// ComponentsStatus tracks the status of individual components
 	ComponentsStatus []ComponentStatus `json:"componentsStatus"`
 
 	// Conditions represent the latest available observations of the VirtoolApp's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// History records the most recent version changes, oldest first
+	History []UpgradeRecord `json:"history,omitempty"`
+
+	// Drift records the most recent changes made to managed objects by
+	// someone other than the operator, oldest first
+	Drift []DriftRecord `json:"drift,omitempty"`
+
+	// ImageDigests records the digests the image tags of components and
+	// update jobs were pinned to, oldest first
+	ImageDigests []ImageDigest `json:"imageDigests,omitempty"`
+}
+
+// DriftPolicy decides what happens to managed objects that are changed by
+// someone other than the operator
+// +kubebuilder:validation:Enum=Correct;ReportOnly
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect sets drifted objects back to their desired state
+	DriftPolicyCorrect DriftPolicy = "Correct"
+
+	// DriftPolicyReportOnly records drifted fields without changing them
+	DriftPolicyReportOnly DriftPolicy = "ReportOnly"
+)
+
+// DriftRecord describes a managed object whose fields were changed by
+// someone other than the operator
+type DriftRecord struct {
+	// Kind is the kind of the object
+	Kind string `json:"kind"`
+
+	// Name is the name of the object
+	Name string `json:"name"`
+
+	// Fields are the paths of the fields that were changed
+	Fields []string `json:"fields,omitempty"`
+
+	// Managers are the field managers that changed them
+	Managers []string `json:"managers,omitempty"`
+
+	// DetectionTime is when the drift was first detected
+	DetectionTime metav1.Time `json:"detectionTime"`
+
+	// CorrectionTime is when the object was set back to its desired state. It
+	// is not set while the drift is only reported.
+	CorrectionTime *metav1.Time `json:"correctionTime,omitempty"`
+}
+
+// ImageDigest records the digest an image tag was pinned to
+type ImageDigest struct {
+	// Image is the tagged image, for example ghcr.io/virtool/virtool:7.1.0
+	Image string `json:"image"`
+
+	// Digest is the digest the tag pointed to when it was pinned
+	Digest string `json:"digest"`
+
+	// ResolveTime is when the tag was pinned
+	ResolveTime metav1.Time `json:"resolveTime"`
+
+	// VerifyTime is when the tag was last checked against the registry
+	VerifyTime metav1.Time `json:"verifyTime"`
+
+	// CurrentDigest is the digest the tag points to now if it has been pushed
+	// again since it was pinned. Components stay pinned to Digest.
+	CurrentDigest string `json:"currentDigest,omitempty"`
+}
+
+// UpgradeRecord describes a version change of the application
+type UpgradeRecord struct {
+	// FromVersion is the version the application was running. It is empty
+	// for the initial installation.
+	FromVersion string `json:"fromVersion,omitempty"`
+
+	// ToVersion is the version the application was changed to
+	ToVersion string `json:"toVersion"`
+
+	// BackupID identifies the backup taken before the change, if any
+	BackupID string `json:"backupID,omitempty"`
+
+	// StartTime is when the change started
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the change finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
 // ComponentStatus tracks the status of an individual component
@@ -86,10 +550,23 @@ type ComponentStatus struct {
 
 	// UpdatedReplicas is the number of replicas that have been updated
 	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// ObservedVersions are the versions the pods of the component run, as
+	// read from their images. More than one is listed while pods of different
+	// versions run side by side, as they do during a rollout.
+	ObservedVersions []string `json:"observedVersions,omitempty"`
+
+	// VersionSkewTime is when pods of the component were first seen running
+	// more than one version. It is cleared once they run a single version.
+	VersionSkewTime *metav1.Time `json:"versionSkewTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Desired",type=string,JSONPath=`.spec.version`
+//+kubebuilder:printcolumn:name="Current",type=string,JSONPath=`.status.currentVersion`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.readyComponents`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // VirtoolApp is the Schema for the virtoolapps API
 type VirtoolApp struct {
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+// ConvertTo converts this VirtoolBackup to the hub version.
+func (src *VirtoolBackup) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.VirtoolBackup)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolBackup but got %T", dstRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec = v1beta1.VirtoolBackupSpec{
+		AppName:   src.Spec.AppName,
+		Schedule:  src.Spec.Schedule,
+		Retention: src.Spec.Retention,
+		Policy:    backupPolicyToHub(src.Spec.Policy),
+	}
+
+	dst.Status = v1beta1.VirtoolBackupStatus{
+		LastScheduleTime: src.Status.LastScheduleTime,
+		Conditions:       src.Status.Conditions,
+	}
+	if src.Status.Backups != nil {
+		dst.Status.Backups = make([]v1beta1.BackupRecord, len(src.Status.Backups))
+		for i, record := range src.Status.Backups {
+			dst.Status.Backups[i] = v1beta1.BackupRecord{
+				ID:             record.ID,
+				Phase:          v1beta1.BackupPhase(record.Phase),
+				AppVersion:     record.AppVersion,
+				Databases:      record.Databases,
+				StartTime:      record.StartTime,
+				CompletionTime: record.CompletionTime,
+			}
+			if record.Volumes != nil {
+				dst.Status.Backups[i].Volumes = make([]v1beta1.VolumeMetadata, len(record.Volumes))
+				for j := range record.Volumes {
+					dst.Status.Backups[i].Volumes[j] = v1beta1.VolumeMetadata(record.Volumes[j])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the hub version to this VirtoolBackup.
+func (dst *VirtoolBackup) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.VirtoolBackup)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolBackup but got %T", srcRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec = VirtoolBackupSpec{
+		AppName:   src.Spec.AppName,
+		Schedule:  src.Spec.Schedule,
+		Retention: src.Spec.Retention,
+		Policy:    backupPolicyFromHub(src.Spec.Policy),
+	}
+
+	dst.Status = VirtoolBackupStatus{
+		LastScheduleTime: src.Status.LastScheduleTime,
+		Conditions:       src.Status.Conditions,
+	}
+	if src.Status.Backups != nil {
+		dst.Status.Backups = make([]BackupRecord, len(src.Status.Backups))
+		for i, record := range src.Status.Backups {
+			dst.Status.Backups[i] = BackupRecord{
+				ID:             record.ID,
+				Phase:          BackupPhase(record.Phase),
+				AppVersion:     record.AppVersion,
+				Databases:      record.Databases,
+				StartTime:      record.StartTime,
+				CompletionTime: record.CompletionTime,
+			}
+			if record.Volumes != nil {
+				dst.Status.Backups[i].Volumes = make([]VolumeMetadata, len(record.Volumes))
+				for j := range record.Volumes {
+					dst.Status.Backups[i].Volumes[j] = VolumeMetadata(record.Volumes[j])
+				}
+			}
+		}
+	}
+
+	return nil
+}
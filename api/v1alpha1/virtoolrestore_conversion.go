@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+// ConvertTo converts this VirtoolRestore to the hub version.
+func (src *VirtoolRestore) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1beta1.VirtoolRestore)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolRestore but got %T", dstRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = v1beta1.VirtoolRestoreSpec(src.Spec)
+	dst.Status = v1beta1.VirtoolRestoreStatus{
+		BackupID:       src.Status.BackupID,
+		Phase:          v1beta1.RestorePhase(src.Status.Phase),
+		StartTime:      src.Status.StartTime,
+		CompletionTime: src.Status.CompletionTime,
+		Conditions:     src.Status.Conditions,
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the hub version to this VirtoolRestore.
+func (dst *VirtoolRestore) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1beta1.VirtoolRestore)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 VirtoolRestore but got %T", srcRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = VirtoolRestoreSpec(src.Spec)
+	dst.Status = VirtoolRestoreStatus{
+		BackupID:       src.Status.BackupID,
+		Phase:          RestorePhase(src.Status.Phase),
+		StartTime:      src.Status.StartTime,
+		CompletionTime: src.Status.CompletionTime,
+		Conditions:     src.Status.Conditions,
+	}
+
+	return nil
+}
@@ -21,13 +21,148 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdoptSpec) DeepCopyInto(out *AdoptSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdoptSpec.
+func (in *AdoptSpec) DeepCopy() *AdoptSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AdoptSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupPolicy) DeepCopyInto(out *BackupPolicy) {
+	*out = *in
+	in.Target.DeepCopyInto(&out.Target)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupPolicy.
+func (in *BackupPolicy) DeepCopy() *BackupPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupRecord) DeepCopyInto(out *BackupRecord) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]VolumeMetadata, len(*in))
+		copy(*out, *in)
+	}
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRecord.
+func (in *BackupRecord) DeepCopy() *BackupRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTarget) DeepCopyInto(out *BackupTarget) {
+	*out = *in
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(PersistentVolumeClaimTarget)
+		**out = **in
+	}
+	if in.ObjectStorage != nil {
+		in, out := &in.ObjectStorage, &out.ObjectStorage
+		*out = new(ObjectStorageTarget)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTarget.
+func (in *BackupTarget) DeepCopy() *BackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChannelSpec) DeepCopyInto(out *ChannelSpec) {
+	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChannelSpec.
+func (in *ChannelSpec) DeepCopy() *ChannelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ChannelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentSpec) DeepCopyInto(out *ComponentSpec) {
 	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.PreUpdateJob != nil {
 		in, out := &in.PreUpdateJob, &out.PreUpdateJob
@@ -39,6 +174,16 @@ func (in *ComponentSpec) DeepCopyInto(out *ComponentSpec) {
 		*out = new(JobSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DisruptionBudget != nil {
+		in, out := &in.DisruptionBudget, &out.DisruptionBudget
+		*out = new(DisruptionBudgetSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Adopt != nil {
+		in, out := &in.Adopt, &out.Adopt
+		*out = new(AdoptSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentSpec.
@@ -54,6 +199,15 @@ func (in *ComponentSpec) DeepCopy() *ComponentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentStatus) DeepCopyInto(out *ComponentStatus) {
 	*out = *in
+	if in.ObservedVersions != nil {
+		in, out := &in.ObservedVersions, &out.ObservedVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VersionSkewTime != nil {
+		in, out := &in.VersionSkewTime, &out.VersionSkewTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentStatus.
@@ -66,6 +220,149 @@ func (in *ComponentStatus) DeepCopy() *ComponentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependenciesSpec) DeepCopyInto(out *DependenciesSpec) {
+	*out = *in
+	if in.Postgres != nil {
+		in, out := &in.Postgres, &out.Postgres
+		*out = new(DependencySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MongoDB != nil {
+		in, out := &in.MongoDB, &out.MongoDB
+		*out = new(DependencySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(DependencySpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependenciesSpec.
+func (in *DependenciesSpec) DeepCopy() *DependenciesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DependenciesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencySpec) DeepCopyInto(out *DependencySpec) {
+	*out = *in
+	if in.Managed != nil {
+		in, out := &in.Managed, &out.Managed
+		*out = new(ManagedDependencySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(ExternalDependencySpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencySpec.
+func (in *DependencySpec) DeepCopy() *DependencySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionBudgetSpec) DeepCopyInto(out *DisruptionBudgetSpec) {
+	*out = *in
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionBudgetSpec.
+func (in *DisruptionBudgetSpec) DeepCopy() *DisruptionBudgetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionBudgetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftRecord) DeepCopyInto(out *DriftRecord) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Managers != nil {
+		in, out := &in.Managers, &out.Managers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.DetectionTime.DeepCopyInto(&out.DetectionTime)
+	if in.CorrectionTime != nil {
+		in, out := &in.CorrectionTime, &out.CorrectionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftRecord.
+func (in *DriftRecord) DeepCopy() *DriftRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalDependencySpec) DeepCopyInto(out *ExternalDependencySpec) {
+	*out = *in
+	in.ConnectionSecret.DeepCopyInto(&out.ConnectionSecret)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalDependencySpec.
+func (in *ExternalDependencySpec) DeepCopy() *ExternalDependencySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalDependencySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageDigest) DeepCopyInto(out *ImageDigest) {
+	*out = *in
+	in.ResolveTime.DeepCopyInto(&out.ResolveTime)
+	in.VerifyTime.DeepCopyInto(&out.VerifyTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageDigest.
+func (in *ImageDigest) DeepCopy() *ImageDigest {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageDigest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 	*out = *in
@@ -79,6 +376,13 @@ func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobSpec.
@@ -91,6 +395,118 @@ func (in *JobSpec) DeepCopy() *JobSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedDependencySpec) DeepCopyInto(out *ManagedDependencySpec) {
+	*out = *in
+	if in.StorageSize != nil {
+		in, out := &in.StorageSize, &out.StorageSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedDependencySpec.
+func (in *ManagedDependencySpec) DeepCopy() *ManagedDependencySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedDependencySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectStorageTarget) DeepCopyInto(out *ObjectStorageTarget) {
+	*out = *in
+	out.CredentialsSecret = in.CredentialsSecret
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectStorageTarget.
+func (in *ObjectStorageTarget) DeepCopy() *ObjectStorageTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectStorageTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PersistentVolumeClaimTarget) DeepCopyInto(out *PersistentVolumeClaimTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistentVolumeClaimTarget.
+func (in *PersistentVolumeClaimTarget) DeepCopy() *PersistentVolumeClaimTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PersistentVolumeClaimTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesSpec) DeepCopyInto(out *ProbesSpec) {
+	*out = *in
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Disabled != nil {
+		in, out := &in.Disabled, &out.Disabled
+		*out = make([]ProbeType, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesSpec.
+func (in *ProbesSpec) DeepCopy() *ProbesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeRecord) DeepCopyInto(out *UpgradeRecord) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeRecord.
+func (in *UpgradeRecord) DeepCopy() *UpgradeRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtoolApp) DeepCopyInto(out *VirtoolApp) {
 	*out = *in
@@ -160,6 +576,21 @@ func (in *VirtoolAppSpec) DeepCopyInto(out *VirtoolAppSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = new(DependenciesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(BackupPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Channel != nil {
+		in, out := &in.Channel, &out.Channel
+		*out = new(ChannelSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolAppSpec.
@@ -175,10 +606,21 @@ func (in *VirtoolAppSpec) DeepCopy() *VirtoolAppSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtoolAppStatus) DeepCopyInto(out *VirtoolAppStatus) {
 	*out = *in
+	if in.UpgradePath != nil {
+		in, out := &in.UpgradePath, &out.UpgradePath
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReleaseCheckTime != nil {
+		in, out := &in.LastReleaseCheckTime, &out.LastReleaseCheckTime
+		*out = (*in).DeepCopy()
+	}
 	if in.ComponentsStatus != nil {
 		in, out := &in.ComponentsStatus, &out.ComponentsStatus
 		*out = make([]ComponentStatus, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -187,6 +629,27 @@ func (in *VirtoolAppStatus) DeepCopyInto(out *VirtoolAppStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]UpgradeRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = make([]DriftRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImageDigests != nil {
+		in, out := &in.ImageDigests, &out.ImageDigests
+		*out = make([]ImageDigest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolAppStatus.
@@ -198,3 +661,239 @@ func (in *VirtoolAppStatus) DeepCopy() *VirtoolAppStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolBackup) DeepCopyInto(out *VirtoolBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolBackup.
+func (in *VirtoolBackup) DeepCopy() *VirtoolBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtoolBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolBackupList) DeepCopyInto(out *VirtoolBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VirtoolBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolBackupList.
+func (in *VirtoolBackupList) DeepCopy() *VirtoolBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtoolBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolBackupSpec) DeepCopyInto(out *VirtoolBackupSpec) {
+	*out = *in
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(BackupPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolBackupSpec.
+func (in *VirtoolBackupSpec) DeepCopy() *VirtoolBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolBackupStatus) DeepCopyInto(out *VirtoolBackupStatus) {
+	*out = *in
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]BackupRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolBackupStatus.
+func (in *VirtoolBackupStatus) DeepCopy() *VirtoolBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolRestore) DeepCopyInto(out *VirtoolRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolRestore.
+func (in *VirtoolRestore) DeepCopy() *VirtoolRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtoolRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolRestoreList) DeepCopyInto(out *VirtoolRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VirtoolRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolRestoreList.
+func (in *VirtoolRestoreList) DeepCopy() *VirtoolRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VirtoolRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolRestoreSpec) DeepCopyInto(out *VirtoolRestoreSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolRestoreSpec.
+func (in *VirtoolRestoreSpec) DeepCopy() *VirtoolRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtoolRestoreStatus) DeepCopyInto(out *VirtoolRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtoolRestoreStatus.
+func (in *VirtoolRestoreStatus) DeepCopy() *VirtoolRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtoolRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeMetadata) DeepCopyInto(out *VolumeMetadata) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMetadata.
+func (in *VolumeMetadata) DeepCopy() *VolumeMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bryce-davidson/virtool-operator/internal/compatibility"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var virtoolapplog = logf.Log.WithName("virtoolapp-resource")
+
+// SetupWebhookWithManager registers the VirtoolApp webhooks, checking version
+// changes against the compatibility manifest returned by compat.
+func (r *VirtoolApp) SetupWebhookWithManager(mgr ctrl.Manager, compat *compatibility.Loader) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&VirtoolAppValidator{Compatibility: compat}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-virtool-virtool-ca-v1beta1-virtoolapp,mutating=false,failurePolicy=fail,sideEffects=None,groups=virtool.virtool.ca,resources=virtoolapps,verbs=create;update,versions=v1beta1,name=vvirtoolapp.kb.io,admissionReviewVersions=v1
+
+// VirtoolAppValidator refuses version changes that the compatibility manifest
+// does not allow, such as downgrades, components without an image and
+// component images pinned to versions that do not work with the desired
+// version.
+// +kubebuilder:object:generate=false
+type VirtoolAppValidator struct {
+	Compatibility *compatibility.Loader
+}
+
+var _ webhook.CustomValidator = &VirtoolAppValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *VirtoolAppValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	app, ok := obj.(*VirtoolApp)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtoolApp but got %T", obj)
+	}
+	virtoolapplog.Info("validate create", "name", app.Name)
+
+	if err := validateImages(app); err != nil {
+		return nil, err
+	}
+
+	manifest, err := v.Compatibility.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifest.CheckComponents(app.Spec.Version, app.PinnedComponentVersions()); err != nil {
+		return nil, invalidVersion(app, err)
+	}
+
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *VirtoolAppValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*VirtoolApp)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtoolApp but got %T", oldObj)
+	}
+	app, ok := newObj.(*VirtoolApp)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtoolApp but got %T", newObj)
+	}
+	virtoolapplog.Info("validate update", "name", app.Name)
+
+	if err := validateImages(app); err != nil {
+		return nil, err
+	}
+
+	manifest, err := v.Compatibility.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifest.CheckComponents(app.Spec.Version, app.PinnedComponentVersions()); err != nil {
+		return nil, invalidVersion(app, err)
+	}
+
+	if app.Spec.Version == old.Spec.Version {
+		return nil, nil
+	}
+
+	hops, err := manifest.Plan(old.Status.CurrentVersion, app.Spec.Version)
+	if err != nil {
+		return nil, invalidVersion(app, err)
+	}
+
+	if len(hops) > 1 {
+		return admission.Warnings{fmt.Sprintf("upgrading from %s to %s through %s",
+			old.Status.CurrentVersion, app.Spec.Version, strings.Join(hops[:len(hops)-1], ", "))}, nil
+	}
+
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *VirtoolAppValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// PinnedComponentVersions returns the versions that components with a role
+// are pinned to by a tag on their image, keyed by role. Components without a
+// tag follow the version of the app.
+func (r *VirtoolApp) PinnedComponentVersions() map[string]string {
+	versions := map[string]string{}
+
+	for i := range r.Spec.Components {
+		component := &r.Spec.Components[i]
+		image := r.Spec.ComponentImage(component)
+		if component.Role == "" || strings.Contains(image, "@") {
+			continue
+		}
+
+		name := image[strings.LastIndex(image, "/")+1:]
+		if _, tag, ok := strings.Cut(name, ":"); ok {
+			versions[string(component.Role)] = tag
+		}
+	}
+
+	return versions
+}
+
+// validateImages refuses components that have neither an image nor a role
+// with a default image.
+func validateImages(app *VirtoolApp) error {
+	var errs field.ErrorList
+	for i := range app.Spec.Components {
+		component := &app.Spec.Components[i]
+		if app.Spec.ComponentImage(component) == "" {
+			errs = append(errs, field.Required(field.NewPath("spec", "components").Index(i).Child("image"),
+				fmt.Sprintf("components with role %q must set an image", component.Role)))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("VirtoolApp").GroupKind(), app.Name, errs)
+}
+
+func invalidVersion(app *VirtoolApp, err error) error {
+	return apierrors.NewInvalid(GroupVersion.WithKind("VirtoolApp").GroupKind(), app.Name, field.ErrorList{
+		field.Invalid(field.NewPath("spec", "version"), app.Spec.Version, err.Error()),
+	})
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bryce-davidson/virtool-operator/internal/compatibility"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testValidator() *VirtoolAppValidator {
+	key := types.NamespacedName{Namespace: "virtool-operator-system", Name: "compatibility"}
+
+	return &VirtoolAppValidator{Compatibility: &compatibility.Loader{
+		Reader: fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data: map[string]string{compatibility.ConfigMapKey: `
+releases:
+  - version: 7.0.0
+    upgradeFrom: 6.2.0
+    components:
+      ui: ">=7.0.0 <8.0.0"
+`},
+		}).Build(),
+		ConfigMap: key,
+	}}
+}
+
+func testApp(version, currentVersion, uiImage string) *VirtoolApp {
+	return &VirtoolApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "virtool", Namespace: "default"},
+		Spec: VirtoolAppSpec{
+			Version: version,
+			Components: []ComponentSpec{
+				{Name: "api", Role: ComponentRoleAPI, Image: "ghcr.io/virtool/virtool"},
+				{Name: "ui", Role: ComponentRoleUI, Image: uiImage},
+			},
+		},
+		Status: VirtoolAppStatus{CurrentVersion: currentVersion},
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	v := testValidator()
+
+	if _, err := v.ValidateCreate(context.Background(), testApp("7.1.0", "", "ghcr.io/virtool/ui")); err != nil {
+		t.Errorf("expected an unpinned ui to be accepted, got %v", err)
+	}
+	if _, err := v.ValidateCreate(context.Background(), testApp("7.1.0", "", "ghcr.io/virtool/ui:7.0.2")); err != nil {
+		t.Errorf("expected a compatible ui to be accepted, got %v", err)
+	}
+	if _, err := v.ValidateCreate(context.Background(), testApp("7.1.0", "", "ghcr.io/virtool/ui:6.3.0")); err == nil {
+		t.Error("expected an incompatible ui to be refused")
+	}
+}
+
+func TestValidateCreateImages(t *testing.T) {
+	v := testValidator()
+
+	app := testApp("7.1.0", "", "")
+	if _, err := v.ValidateCreate(context.Background(), app); err != nil {
+		t.Errorf("expected role images to be filled in from the image repository, got %v", err)
+	}
+
+	app.Spec.Components = append(app.Spec.Components, ComponentSpec{Name: "workflow-nuvs", Role: ComponentRoleWorkflowRunner})
+	if _, err := v.ValidateCreate(context.Background(), app); err == nil {
+		t.Error("expected a workflow runner without an image to be refused")
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	v := testValidator()
+
+	tests := []struct {
+		name         string
+		from, to     string
+		ok           bool
+		wantWarnings int
+	}{
+		{"direct upgrade", "7.0.0", "7.1.0", true, 0},
+		{"upgrade through a listed release", "6.2.0", "7.1.0", true, 1},
+		{"multi-hop upgrade", "6.1.0", "7.1.0", true, 1},
+		{"downgrade", "7.1.0", "6.9.0", false, 0},
+		{"fresh install", "", "7.1.0", true, 0},
+	}
+
+	for _, tt := range tests {
+		old := testApp(tt.from, tt.from, "ghcr.io/virtool/ui")
+		if tt.from == "" {
+			old.Spec.Version = "6.0.0"
+		}
+
+		warnings, err := v.ValidateUpdate(context.Background(), old, testApp(tt.to, tt.from, "ghcr.io/virtool/ui"))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want ok=%t", tt.name, err, tt.ok)
+		}
+		if len(warnings) != tt.wantWarnings {
+			t.Errorf("%s: got warnings %v, want %d", tt.name, warnings, tt.wantWarnings)
+		}
+	}
+}
+
+func TestPinnedComponentVersions(t *testing.T) {
+	app := testApp("7.1.0", "", "registry.example.com:5000/virtool/ui:7.0.2")
+	app.Spec.Components = append(app.Spec.Components,
+		ComponentSpec{Name: "runner", Role: ComponentRoleWorkflowRunner, Image: "ghcr.io/virtool/workflow@sha256:abc"},
+		ComponentSpec{Name: "sidecar", Image: "nginx:1.25"},
+	)
+
+	got := app.PinnedComponentVersions()
+	if len(got) != 1 || got["ui"] != "7.0.2" {
+		t.Errorf("got %v, want only ui pinned to 7.0.2", got)
+	}
+}
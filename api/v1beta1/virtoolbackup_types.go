@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VirtoolBackupSpec defines the desired state of VirtoolBackup
+type VirtoolBackupSpec struct {
+	// AppName is the name of the VirtoolApp to back up. It must be in the same
+	// namespace as the backup.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="appName is immutable"
+	AppName string `json:"appName"`
+
+	// Schedule is a cron schedule for taking backups. A single backup is taken
+	// when it is not set.
+	Schedule string `json:"schedule,omitempty"`
+
+	// Retention is the number of successful backups to keep. Older backups
+	// are deleted from the target.
+	// +kubebuilder:default=7
+	// +kubebuilder:validation:Minimum=1
+	Retention *int32 `json:"retention,omitempty"`
+
+	// Policy configures where backups are written and the images used to take
+	// them. The backup policy of the app is used when it is not set.
+	Policy *BackupPolicy `json:"policy,omitempty"`
+}
+
+// BackupPhase is the state of a single backup
+type BackupPhase string
+
+const (
+	// BackupPhaseRunning means the backup is being taken
+	BackupPhaseRunning BackupPhase = "Running"
+
+	// BackupPhaseSucceeded means the backup was written to its target
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+
+	// BackupPhaseFailed means the backup could not be taken
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+const (
+	// ConditionProgressing indicates that a backup or restore is in progress
+	ConditionProgressing = "Progressing"
+
+	// ConditionComplete indicates that the most recent backup or the restore
+	// finished successfully
+	ConditionComplete = "Complete"
+)
+
+// VirtoolBackupStatus defines the observed state of VirtoolBackup
+type VirtoolBackupStatus struct {
+	// Backups lists the retained backups, oldest first
+	Backups []BackupRecord `json:"backups,omitempty"`
+
+	// LastScheduleTime is when the most recent backup was started
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Conditions represent the latest available observations of the backup's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// BackupRecord describes a single backup of an app
+type BackupRecord struct {
+	// ID identifies the backup within its target
+	ID string `json:"id"`
+
+	// Phase is the state of the backup
+	Phase BackupPhase `json:"phase"`
+
+	// AppVersion is the version of the app when the backup was taken
+	AppVersion string `json:"appVersion,omitempty"`
+
+	// Databases are the datastores included in the backup
+	Databases []string `json:"databases,omitempty"`
+
+	// Volumes describes the persistent volumes of the app when the backup was
+	// taken. Their contents are not included in the backup.
+	Volumes []VolumeMetadata `json:"volumes,omitempty"`
+
+	// StartTime is when the backup was started
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the backup finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// VolumeMetadata describes a persistent volume claim of an app
+type VolumeMetadata struct {
+	// ClaimName is the name of the persistent volume claim
+	ClaimName string `json:"claimName"`
+
+	// VolumeName is the name of the bound persistent volume
+	VolumeName string `json:"volumeName,omitempty"`
+
+	// StorageClassName is the storage class of the claim
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Capacity is the capacity of the bound volume
+	Capacity string `json:"capacity,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="App",type=string,JSONPath=`.spec.appName`
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.lastScheduleTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+//+kubebuilder:storageversion
+
+// VirtoolBackup is the Schema for the virtoolbackups API
+type VirtoolBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtoolBackupSpec   `json:"spec,omitempty"`
+	Status VirtoolBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VirtoolBackupList contains a list of VirtoolBackup
+type VirtoolBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtoolBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtoolBackup{}, &VirtoolBackupList{})
+}
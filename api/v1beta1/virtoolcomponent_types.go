@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VirtoolComponentSpec defines the desired state of a single component of a
+// VirtoolApp. It is written by the VirtoolApp controller with the component's
+// role template, image and environment already resolved, except for Replicas,
+// which is only copied from the app when the app's value changes so that it
+// can be scaled independently.
+type VirtoolComponentSpec struct {
+	// AppName is the name of the VirtoolApp the component belongs to
+	// +kubebuilder:validation:MinLength=1
+	AppName string `json:"appName"`
+
+	// ComponentName is the name of the component within the app
+	// +kubebuilder:validation:MinLength=1
+	ComponentName string `json:"componentName"`
+
+	// Role is the role of the component, if any
+	Role ComponentRole `json:"role,omitempty"`
+
+	// Version is the Virtool version the component runs
+	Version string `json:"version"`
+
+	// Image is the container image of the component. It is tagged with
+	// Version unless it already carries a tag or digest.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Command overrides the entrypoint of the component's container
+	Command []string `json:"command,omitempty"`
+
+	// Args overrides the arguments of the component's container
+	Args []string `json:"args,omitempty"`
+
+	// Env is the environment of the component's container
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Replicas is the desired number of pods of the component. It is the
+	// target of the scale subresource.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// Port is the container port the component serves HTTP on, if any
+	Port int32 `json:"port,omitempty"`
+
+	// Probes are the health probes of the component's container
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// Resources defines the resource requirements for the component
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Suspended scales the component to zero pods without changing Replicas,
+	// for example while a backup is restored into the app
+	Suspended bool `json:"suspended,omitempty"`
+
+	// DriftPolicy decides what the operator does when the component's
+	// workload is changed by someone else
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// DeploymentName is the name of the component's Deployment. It is the
+	// name of the VirtoolComponent unless an existing Deployment was adopted.
+	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// AdoptedVersion is the version an adopted Deployment was running when
+	// it was adopted. Its pod template is left as it is while the component
+	// stays at this version so that adopting it does not restart its pods.
+	AdoptedVersion string `json:"adoptedVersion,omitempty"`
+}
+
+// VirtoolComponentStatus defines the observed state of a component
+type VirtoolComponentStatus struct {
+	// ObservedGeneration is the generation of the spec most recently reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CurrentVersion is the version the component's workload was last
+	// rendered for
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// Replicas is the number of pods of the component
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of pods that are ready
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of pods running the latest template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Selector is the label selector of the component's pods in string form,
+	// as used by the scale subresource
+	Selector string `json:"selector,omitempty"`
+
+	// Conditions represent the latest available observations of the component's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Drift records the most recent changes made to the component's workload
+	// by someone other than the operator, oldest first
+	Drift []DriftRecord `json:"drift,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:printcolumn:name="App",type=string,JSONPath=`.spec.appName`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+//+kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.replicas`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// VirtoolComponent is the Schema for the virtoolcomponents API. The VirtoolApp
+// controller creates one for each component that runs as a long-running
+// workload, so that components can be scaled with kubectl scale or a
+// HorizontalPodAutoscaler.
+type VirtoolComponent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtoolComponentSpec   `json:"spec,omitempty"`
+	Status VirtoolComponentStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VirtoolComponentList contains a list of VirtoolComponent
+type VirtoolComponentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtoolComponent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtoolComponent{}, &VirtoolComponentList{})
+}
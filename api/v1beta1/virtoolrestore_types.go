@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VirtoolRestoreSpec defines the desired state of VirtoolRestore
+type VirtoolRestoreSpec struct {
+	// BackupName is the name of the VirtoolBackup holding the backup to restore
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="backupName is immutable"
+	BackupName string `json:"backupName"`
+
+	// BackupID selects the backup to restore. The most recent successful
+	// backup is restored when it is not set.
+	BackupID string `json:"backupID,omitempty"`
+
+	// AppName is the name of the VirtoolApp to restore the backup into. Its
+	// components are scaled down while the databases are restored.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="appName is immutable"
+	AppName string `json:"appName"`
+}
+
+// RestorePhase is the stage a restore has reached
+type RestorePhase string
+
+const (
+	// RestorePhaseScalingDown waits for the app's components to stop
+	RestorePhaseScalingDown RestorePhase = "ScalingDown"
+
+	// RestorePhaseRestoring restores the databases from the backup
+	RestorePhaseRestoring RestorePhase = "Restoring"
+
+	// RestorePhaseSucceeded means the backup was restored and the app resumed
+	RestorePhaseSucceeded RestorePhase = "Succeeded"
+
+	// RestorePhaseFailed means the backup could not be restored. The app's
+	// components are scaled back up.
+	RestorePhaseFailed RestorePhase = "Failed"
+)
+
+// VirtoolRestoreStatus defines the observed state of VirtoolRestore
+type VirtoolRestoreStatus struct {
+	// BackupID is the backup being restored
+	BackupID string `json:"backupID,omitempty"`
+
+	// Phase is the stage the restore has reached
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// StartTime is when the restore was started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions represent the latest available observations of the restore's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="App",type=string,JSONPath=`.spec.appName`
+//+kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.status.backupID`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+//+kubebuilder:storageversion
+
+// VirtoolRestore is the Schema for the virtoolrestores API
+type VirtoolRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtoolRestoreSpec   `json:"spec,omitempty"`
+	Status VirtoolRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// VirtoolRestoreList contains a list of VirtoolRestore
+type VirtoolRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VirtoolRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VirtoolRestore{}, &VirtoolRestoreList{})
+}
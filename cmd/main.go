@@ -17,25 +17,34 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"go.opentelemetry.io/otel"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	virtoolv1alpha1 "github.com/bryce-davidson/virtool-operator/api/v1alpha1"
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/compatibility"
 	"github.com/bryce-davidson/virtool-operator/internal/controller"
+	"github.com/bryce-davidson/virtool-operator/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -48,6 +57,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(virtoolv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(virtoolv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -57,6 +67,12 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var compatibilityConfigMap string
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var traceSampleRatio float64
+	var watchNamespaces string
+	var versionSkewThreshold time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +82,18 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&compatibilityConfigMap, "compatibility-configmap", "",
+		"The namespace/name of a ConfigMap whose manifest.yaml key overrides the embedded compatibility manifest.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The host:port or URL of an OTLP/HTTP collector to export traces to. Tracing is disabled if empty.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"If set, traces are exported over plain HTTP instead of HTTPS")
+	flag.Float64Var(&traceSampleRatio, "trace-sample-ratio", 1,
+		"The fraction of reconciles and upgrades that are traced, between 0 and 1.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"A comma-separated list of the namespaces to manage VirtoolApps in. All namespaces are watched if empty.")
+	flag.DurationVar(&versionSkewThreshold, "version-skew-threshold", 15*time.Minute,
+		"How long the pods of a component may run more than one version before the app reports a VersionSkew.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -74,6 +102,22 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	shutdownTracing := func(context.Context) error { return nil }
+	if otlpEndpoint != "" {
+		tp, err := tracing.NewTracerProvider(tracing.Options{
+			Endpoint:    otlpEndpoint,
+			Insecure:    otlpInsecure,
+			SampleRatio: traceSampleRatio,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		otel.SetTracerProvider(tp)
+		shutdownTracing = tp.Shutdown
+		setupLog.Info("exporting traces", "endpoint", otlpEndpoint)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -94,8 +138,22 @@ func main() {
 		TLSOpts: tlsOpts,
 	})
 
+	// Only cache the workloads the operator manages itself rather than every
+	// pod, deployment and job in the cluster.
+	cacheOpts := cache.Options{
+		ByObject: controller.CacheByObject(),
+	}
+	if namespaces := parseNamespaces(watchNamespaces); len(namespaces) > 0 {
+		cacheOpts.DefaultNamespaces = make(map[string]cache.Config, len(namespaces))
+		for _, namespace := range namespaces {
+			cacheOpts.DefaultNamespaces[namespace] = cache.Config{}
+		}
+		setupLog.Info("watching namespaces", "namespaces", namespaces)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOpts,
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -122,15 +180,65 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger := ctrl.Log.WithName("virtool-controller")
+	compat := &compatibility.Loader{Reader: mgr.GetAPIReader()}
+	if compatibilityConfigMap != "" {
+		namespace, name, ok := strings.Cut(compatibilityConfigMap, "/")
+		if !ok {
+			setupLog.Error(nil, "compatibility-configmap must be in namespace/name form", "value", compatibilityConfigMap)
+			os.Exit(1)
+		}
+		compat.ConfigMap = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
 	if err = (&controller.VirtoolAppReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		Compatibility:        compat,
+		Recorder:             mgr.GetEventRecorderFor("virtoolapp-controller"),
+		APIReader:            mgr.GetAPIReader(),
+		VersionSkewThreshold: versionSkewThreshold,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VirtoolApp")
+		os.Exit(1)
+	}
+	if err = (&controller.VirtoolComponentReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("virtoolcomponent-controller"),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VirtoolComponent")
+		os.Exit(1)
+	}
+	if err = (&controller.VirtoolBackupReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VirtoolBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.VirtoolRestoreReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
-		Log:    logger,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "VirtoolApp")
+		setupLog.Error(err, "unable to create controller", "controller", "VirtoolRestore")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&virtoolv1beta1.VirtoolApp{}).SetupWebhookWithManager(mgr, compat); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VirtoolApp")
+			os.Exit(1)
+		}
+		if err = (&virtoolv1beta1.VirtoolBackup{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VirtoolBackup")
+			os.Exit(1)
+		}
+		if err = (&virtoolv1beta1.VirtoolRestore{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VirtoolRestore")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -147,4 +255,23 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// Flush the spans of the last reconciles before exiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
+}
+
+// parseNamespaces splits a comma-separated list of namespaces, ignoring
+// surrounding whitespace and empty entries.
+func parseNamespaces(value string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(value, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
 }
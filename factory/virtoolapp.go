@@ -5,10 +5,10 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	virtoolv1alpha1 "github.com/bryce-davidson/virtool-operator/api/v1alpha1"
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
 )
 
-type VirtoolAppOption func(*virtoolv1alpha1.VirtoolApp)
+type VirtoolAppOption func(*virtoolv1beta1.VirtoolApp)
 
 const (
 	defaultVersion       = "1.0.0"
@@ -18,8 +18,25 @@ const (
 	defaultMemoryLimit   = "128Mi"
 	defaultCPURequest    = "50m"
 	defaultMemoryRequest = "64Mi"
+
+	// ServerImage is the image providing the Virtool API, jobs API, task
+	// runner and migrations.
+	ServerImage = virtoolv1beta1.DefaultImageRepository + "/virtool"
+
+	// UIImage is the image providing the Virtool web client.
+	UIImage = virtoolv1beta1.DefaultImageRepository + "/ui"
 )
 
+// StandardRoles are the roles making up a standard Virtool install, excluding
+// workflow runners.
+var StandardRoles = []virtoolv1beta1.ComponentRole{
+	virtoolv1beta1.ComponentRoleMigration,
+	virtoolv1beta1.ComponentRoleAPI,
+	virtoolv1beta1.ComponentRoleJobsAPI,
+	virtoolv1beta1.ComponentRoleTasks,
+	virtoolv1beta1.ComponentRoleUI,
+}
+
 func defaultResourceRequirements() corev1.ResourceRequirements {
 	return corev1.ResourceRequirements{
 		Limits: corev1.ResourceList{
@@ -33,19 +50,19 @@ func defaultResourceRequirements() corev1.ResourceRequirements {
 	}
 }
 
-func NewVirtoolApp(name, namespace string, opts ...VirtoolAppOption) *virtoolv1alpha1.VirtoolApp {
-	v := &virtoolv1alpha1.VirtoolApp{
+func NewVirtoolApp(name, namespace string, opts ...VirtoolAppOption) *virtoolv1beta1.VirtoolApp {
+	v := &virtoolv1beta1.VirtoolApp{
 		TypeMeta: metav1.TypeMeta{
-			APIVersion: "cache.example.com/v1alpha1",
+			APIVersion: virtoolv1beta1.GroupVersion.String(),
 			Kind:       "VirtoolApp",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
 		},
-		Spec: virtoolv1alpha1.VirtoolAppSpec{
+		Spec: virtoolv1beta1.VirtoolAppSpec{
 			Version: defaultVersion,
-			Components: []virtoolv1alpha1.ComponentSpec{
+			Components: []virtoolv1beta1.ComponentSpec{
 				{
 					Name:      "default",
 					Image:     defaultImage,
@@ -54,8 +71,8 @@ func NewVirtoolApp(name, namespace string, opts ...VirtoolAppOption) *virtoolv1a
 				},
 			},
 		},
-		Status: virtoolv1alpha1.VirtoolAppStatus{
-			ComponentsStatus: []virtoolv1alpha1.ComponentStatus{},
+		Status: virtoolv1beta1.VirtoolAppStatus{
+			ComponentsStatus: []virtoolv1beta1.ComponentStatus{},
 			Conditions:       []metav1.Condition{},
 		},
 	}
@@ -66,3 +83,81 @@ func NewVirtoolApp(name, namespace string, opts ...VirtoolAppOption) *virtoolv1a
 
 	return v
 }
+
+// WithVersion sets the desired Virtool version.
+func WithVersion(version string) VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		v.Spec.Version = version
+	}
+}
+
+// WithComponents replaces the components of the app.
+func WithComponents(components ...virtoolv1beta1.ComponentSpec) VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		v.Spec.Components = components
+	}
+}
+
+// WithRoles replaces the components of the app with one component per role,
+// named after the role. Images, commands, ports, probes and environment are
+// filled in from the role templates by the operator.
+func WithRoles(roles ...virtoolv1beta1.ComponentRole) VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		v.Spec.Components = make([]virtoolv1beta1.ComponentSpec, 0, len(roles))
+		for _, role := range roles {
+			v.Spec.Components = append(v.Spec.Components, RoleComponent(string(role), role))
+		}
+	}
+}
+
+// WithStandardTopology replaces the components of the app with a standard
+// Virtool install made up of StandardRoles.
+func WithStandardTopology() VirtoolAppOption {
+	return WithRoles(StandardRoles...)
+}
+
+// WithWorkflowRunner adds a runner for the workflow with the given name using
+// the given image.
+func WithWorkflowRunner(workflow, image string) VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		component := RoleComponent("workflow-"+workflow, virtoolv1beta1.ComponentRoleWorkflowRunner)
+		component.Image = image
+		v.Spec.Components = append(v.Spec.Components, component)
+	}
+}
+
+// WithManagedDependencies makes the operator provision PostgreSQL, MongoDB and
+// Redis for the app with default settings.
+func WithManagedDependencies() VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		v.Spec.Dependencies = &virtoolv1beta1.DependenciesSpec{
+			Postgres: &virtoolv1beta1.DependencySpec{Managed: &virtoolv1beta1.ManagedDependencySpec{}},
+			MongoDB:  &virtoolv1beta1.DependencySpec{Managed: &virtoolv1beta1.ManagedDependencySpec{}},
+			Redis:    &virtoolv1beta1.DependencySpec{Managed: &virtoolv1beta1.ManagedDependencySpec{}},
+		}
+	}
+}
+
+// WithBackupClaim makes the operator back up the app's databases to the given
+// persistent volume claim before each version change.
+func WithBackupClaim(claimName string) VirtoolAppOption {
+	return func(v *virtoolv1beta1.VirtoolApp) {
+		v.Spec.Strategy.Backup = &virtoolv1beta1.BackupPolicy{
+			Target: virtoolv1beta1.BackupTarget{
+				PersistentVolumeClaim: &virtoolv1beta1.PersistentVolumeClaimTarget{ClaimName: claimName},
+			},
+		}
+	}
+}
+
+// RoleComponent returns a component with the given name and role using the
+// image for the role in the app's image repository and the default replicas
+// and resources.
+func RoleComponent(name string, role virtoolv1beta1.ComponentRole) virtoolv1beta1.ComponentSpec {
+	return virtoolv1beta1.ComponentSpec{
+		Name:      name,
+		Role:      role,
+		Replicas:  defaultReplicas,
+		Resources: defaultResourceRequirements(),
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command gen-monitoring writes the PrometheusRule alerts and Grafana
+// dashboard for the operator's metrics under the config directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bryce-davidson/virtool-operator/internal/monitoring"
+)
+
+func main() {
+	var configDir string
+	flag.StringVar(&configDir, "config-dir", "config", "The kustomize config directory to write the files under.")
+	flag.Parse()
+
+	files, err := monitoring.Files()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for path, data := range files {
+		path = filepath.Join(configDir, path)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package compatibility describes which Virtool versions can be upgraded to
+// directly and which component versions each release works with.
+package compatibility
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrDowngrade is returned when asked to plan a move to an older version.
+// Database migrations only run forwards.
+var ErrDowngrade = errors.New("downgrades are not supported")
+
+//go:embed manifest.yaml
+var defaultManifest []byte
+
+// Manifest lists the Virtool releases that constrain upgrades. Upgrades stop
+// at each listed release on the way to a later one. Releases that are not
+// listed can be upgraded to from any earlier version and are passed over.
+type Manifest struct {
+	Releases []Release `json:"releases"`
+}
+
+// Release describes the upgrade requirements of a single Virtool release.
+type Release struct {
+	// Version is the version of the release.
+	Version string `json:"version"`
+
+	// UpgradeFrom is the oldest version that can be upgraded past this
+	// release directly. Older installations first upgrade to UpgradeFrom.
+	UpgradeFrom string `json:"upgradeFrom,omitempty"`
+
+	// Components maps component roles, such as "ui", to a constraint on the
+	// component versions that work with this release and later ones.
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// Default returns the manifest embedded in the operator.
+func Default() *Manifest {
+	m, err := Parse(defaultManifest)
+	if err != nil {
+		panic(fmt.Sprintf("parsing embedded compatibility manifest: %v", err))
+	}
+
+	return m
+}
+
+// Parse reads a manifest from YAML and checks that it is consistent.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing compatibility manifest: %w", err)
+	}
+
+	for _, release := range m.Releases {
+		v, err := semver.NewVersion(release.Version)
+		if err != nil {
+			return nil, fmt.Errorf("release %q: %w", release.Version, err)
+		}
+
+		if release.UpgradeFrom != "" {
+			from, err := semver.NewVersion(release.UpgradeFrom)
+			if err != nil {
+				return nil, fmt.Errorf("release %s: upgradeFrom %q: %w", release.Version, release.UpgradeFrom, err)
+			}
+			if !from.LessThan(v) {
+				return nil, fmt.Errorf("release %s: upgradeFrom %s is not an earlier version",
+					release.Version, release.UpgradeFrom)
+			}
+		}
+
+		for role, constraint := range release.Components {
+			if _, err := semver.NewConstraint(constraint); err != nil {
+				return nil, fmt.Errorf("release %s: component %s: %w", release.Version, role, err)
+			}
+		}
+	}
+
+	sort.Slice(m.Releases, func(i, j int) bool {
+		return semver.MustParse(m.Releases[i].Version).LessThan(semver.MustParse(m.Releases[j].Version))
+	})
+
+	return &m, nil
+}
+
+// Plan returns the versions to upgrade through, in order, to move from one
+// version to another. The last version is always to. The upgrade stops at
+// every release listed in the manifest on the way, so that the update jobs of
+// each of them run, and at the version each of those releases must be
+// upgraded from, if that is newer than from. Moves from or to versions that
+// are not valid semantic versions are made directly.
+func (m *Manifest) Plan(from, to string) ([]string, error) {
+	if from == "" || from == to {
+		return []string{to}, nil
+	}
+
+	vFrom, errFrom := semver.NewVersion(from)
+	vTo, errTo := semver.NewVersion(to)
+	if errFrom != nil || errTo != nil {
+		return []string{to}, nil
+	}
+
+	if vTo.LessThan(vFrom) {
+		return nil, fmt.Errorf("moving from %s to %s: %w", from, to, ErrDowngrade)
+	}
+
+	stops := map[string]*semver.Version{to: vTo}
+	for _, release := range m.Releases {
+		v := semver.MustParse(release.Version)
+		if !v.GreaterThan(vFrom) || v.GreaterThan(vTo) {
+			continue
+		}
+		stops[release.Version] = v
+
+		if release.UpgradeFrom != "" {
+			if required := semver.MustParse(release.UpgradeFrom); required.GreaterThan(vFrom) {
+				stops[release.UpgradeFrom] = required
+			}
+		}
+	}
+
+	hops := make([]string, 0, len(stops))
+	for version := range stops {
+		hops = append(hops, version)
+	}
+	sort.Slice(hops, func(i, j int) bool {
+		return stops[hops[i]].LessThan(stops[hops[j]])
+	})
+
+	return hops, nil
+}
+
+// CheckComponents checks that component versions, keyed by component role,
+// work with the given Virtool version. Versions that are not valid semantic
+// versions are not checked.
+func (m *Manifest) CheckComponents(version string, components map[string]string) error {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+
+	constraints := map[string]string{}
+	for _, release := range m.Releases {
+		if semver.MustParse(release.Version).GreaterThan(v) {
+			break
+		}
+		for role, constraint := range release.Components {
+			constraints[role] = constraint
+		}
+	}
+
+	roles := make([]string, 0, len(components))
+	for role := range components {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		constraint, ok := constraints[role]
+		if !ok {
+			continue
+		}
+
+		cv, err := semver.NewVersion(components[role])
+		if err != nil {
+			continue
+		}
+
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return fmt.Errorf("component %s: %w", role, err)
+		}
+
+		if !c.Check(cv) {
+			return fmt.Errorf("%s version %s does not work with Virtool %s, which requires %s",
+				role, components[role], version, constraint)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compatibility
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testManifest = `
+releases:
+  - version: 8.0.0
+    upgradeFrom: 7.4.0
+    components:
+      ui: ">=8.0.0 <9.0.0"
+  - version: 7.2.0
+  - version: 7.0.0
+    upgradeFrom: 6.2.0
+    components:
+      ui: ">=7.0.0 <8.0.0"
+  - version: 6.2.0
+    upgradeFrom: 6.0.0
+`
+
+func TestDefault(t *testing.T) {
+	if len(Default().Releases) == 0 {
+		t.Error("expected the embedded manifest to list releases")
+	}
+}
+
+func TestParse(t *testing.T) {
+	invalid := map[string]string{
+		"unknown field":      "releases:\n  - version: 7.0.0\n    requires: 6.0.0\n",
+		"invalid version":    "releases:\n  - version: seven\n",
+		"later upgradeFrom":  "releases:\n  - version: 7.0.0\n    upgradeFrom: 7.1.0\n",
+		"invalid constraint": "releases:\n  - version: 7.0.0\n    components:\n      ui: \"~~7\"\n",
+	}
+
+	for name, manifest := range invalid {
+		if _, err := Parse([]byte(manifest)); err == nil {
+			t.Errorf("%s: expected the manifest to be rejected", name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"", "8.1.0", []string{"8.1.0"}},
+		{"7.4.0", "7.4.0", []string{"7.4.0"}},
+		{"7.4.0", "8.1.0", []string{"8.0.0", "8.1.0"}},
+		{"8.0.0", "8.1.0", []string{"8.1.0"}},
+		{"7.3.0", "8.1.0", []string{"7.4.0", "8.0.0", "8.1.0"}},
+		{"6.2.5", "7.1.0", []string{"7.0.0", "7.1.0"}},
+		{"6.1.0", "7.1.0", []string{"6.2.0", "7.0.0", "7.1.0"}},
+		{"5.9.0", "8.0.0", []string{"6.0.0", "6.2.0", "7.0.0", "7.2.0", "7.4.0", "8.0.0"}},
+		{"5.9.0", "6.1.0", []string{"6.1.0"}},
+		{"7.0.0", "7.1.0", []string{"7.1.0"}},
+		{"7.0.0", "7.6.0", []string{"7.2.0", "7.6.0"}},
+		{"latest", "8.1.0", []string{"8.1.0"}},
+	}
+
+	for _, tt := range tests {
+		got, err := m.Plan(tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s to %s: %v", tt.from, tt.to, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s to %s: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if _, err := m.Plan("7.1.0", "7.0.0"); !errors.Is(err, ErrDowngrade) {
+		t.Errorf("expected a downgrade to be refused, got %v", err)
+	}
+}
+
+func TestCheckComponents(t *testing.T) {
+	m, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version    string
+		components map[string]string
+		ok         bool
+	}{
+		{"7.2.0", map[string]string{"ui": "7.1.0"}, true},
+		{"7.2.0", map[string]string{"ui": "6.9.0"}, false},
+		{"8.0.0", map[string]string{"ui": "7.1.0"}, false},
+		{"6.5.0", map[string]string{"ui": "5.0.0"}, true},
+		{"7.2.0", map[string]string{"api": "1.0.0"}, true},
+		{"7.2.0", map[string]string{"ui": "latest"}, true},
+	}
+
+	for _, tt := range tests {
+		err := m.CheckComponents(tt.version, tt.components)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s with %v: got %v, want ok=%t", tt.version, tt.components, err, tt.ok)
+		}
+	}
+}
+
+func TestLoader(t *testing.T) {
+	key := types.NamespacedName{Namespace: "virtool-operator-system", Name: "compatibility"}
+
+	loader := &Loader{Reader: fake.NewClientBuilder().Build(), ConfigMap: key}
+	m, err := loader.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, Default()) {
+		t.Error("expected the embedded manifest when the ConfigMap does not exist")
+	}
+
+	loader.Reader = fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string]string{ConfigMapKey: testManifest},
+	}).Build()
+	m, err = loader.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Releases) != 4 || m.Releases[0].Version != "6.2.0" {
+		t.Errorf("expected the manifest from the ConfigMap, got %+v", m.Releases)
+	}
+}
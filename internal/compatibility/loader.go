@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compatibility
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigMapKey is the key of a ConfigMap that holds a manifest.
+const ConfigMapKey = "manifest.yaml"
+
+// Loader reads the manifest from a ConfigMap, falling back to the embedded
+// manifest when no ConfigMap is configured or it does not exist. The zero
+// value always returns the embedded manifest.
+type Loader struct {
+	// Reader reads the ConfigMap. An uncached reader avoids caching every
+	// ConfigMap in the cluster.
+	Reader client.Reader
+
+	// ConfigMap is the ConfigMap holding the manifest under ConfigMapKey.
+	ConfigMap types.NamespacedName
+}
+
+// Load returns the manifest in effect.
+func (l *Loader) Load(ctx context.Context) (*Manifest, error) {
+	if l == nil || l.Reader == nil || l.ConfigMap.Name == "" {
+		return Default(), nil
+	}
+
+	var configMap corev1.ConfigMap
+	if err := l.Reader.Get(ctx, l.ConfigMap, &configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return Default(), nil
+		}
+		return nil, fmt.Errorf("getting compatibility manifest %s: %w", l.ConfigMap, err)
+	}
+
+	data, ok := configMap.Data[ConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("compatibility manifest %s has no %s key", l.ConfigMap, ConfigMapKey)
+	}
+
+	m, err := Parse([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("compatibility manifest %s: %w", l.ConfigMap, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fieldManager is the field manager the operator applies objects as.
+const fieldManager = "virtool-operator"
+
+// legacyFieldManagers are the field managers of objects written by versions
+// of the operator that updated them client-side. Their fields are handed over
+// to fieldManager so that changing them is not reported as a conflict.
+var legacyFieldManagers = sets.New("manager")
+
+// applyObject writes the desired state of obj with server-side apply, so that
+// fields set by other field managers, such as annotations injected by an
+// admission webhook, are left alone. obj must only set the fields the operator
+// manages.
+//
+// Applying a field that another manager has since changed is a conflict, and
+// means the object has drifted from its desired state. The drift is recorded
+// by drift and the desired state is then forced with a warning event on
+// owner, as the operator owns the fields it applies. If the drift policy is
+// ReportOnly only the fields that did not drift are applied instead, and the
+// object is read back into obj. recorder and drift may be nil.
+//
+// The result reports whether the object was created, updated or already in
+// its desired state.
+func applyObject(
+	ctx context.Context,
+	c client.Client,
+	recorder record.EventRecorder,
+	owner client.Object,
+	obj client.Object,
+	drift *driftTracker,
+) (controllerutil.OperationResult, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	runtimeObj, err := c.Scheme().New(gvk)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	current := runtimeObj.(client.Object)
+
+	result := controllerutil.OperationResultUpdated
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); apierrors.IsNotFound(err) {
+		result = controllerutil.OperationResultCreated
+	} else if err != nil {
+		return controllerutil.OperationResultNone, err
+	} else if err := upgradeManagedFields(ctx, c, current); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	logger := log.FromContext(ctx).WithValues("kind", gvk.Kind, "name", obj.GetName())
+
+	err = c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager))
+	if !apierrors.IsConflict(err) {
+		if err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		drift.resolve(gvk.Kind, obj.GetName())
+	} else {
+		fields, managers := conflictingFields(err)
+
+		if drift.reportOnly() {
+			if drift.record(gvk.Kind, obj.GetName(), fields, managers, nil) {
+				logger.Info("Detected drift", "fields", fields, "managers", managers)
+				if recorder != nil {
+					recorder.Eventf(owner, corev1.EventTypeWarning, eventDriftDetected,
+						"%s %s was changed by %s, whose fields are left as they are: %s", gvk.Kind,
+						obj.GetName(), strings.Join(managers, ", "), strings.Join(fields, ", "))
+				}
+			}
+
+			if err := applyUndrifted(ctx, c, obj, fields); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			if obj.GetResourceVersion() == current.GetResourceVersion() {
+				return controllerutil.OperationResultNone, nil
+			}
+			return controllerutil.OperationResultUpdated, nil
+		}
+
+		logger.Info("Forcing conflicting fields", "conflict", err.Error())
+		if recorder != nil {
+			recorder.Eventf(owner, corev1.EventTypeWarning, eventApplyConflict,
+				"Took back fields of %s %s from other field managers: %v", gvk.Kind, obj.GetName(), err)
+		}
+
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		corrected := metav1.Now()
+		drift.record(gvk.Kind, obj.GetName(), fields, managers, &corrected)
+	}
+
+	if result == controllerutil.OperationResultUpdated && obj.GetResourceVersion() == current.GetResourceVersion() {
+		result = controllerutil.OperationResultNone
+	}
+
+	return result, nil
+}
+
+// applyUndrifted applies obj without the fields that drifted, so that the
+// rest of the object is kept at its desired state. Nothing is applied if one
+// of the fields cannot be found in obj, or if the object has drifted further
+// since, which is picked up by the next reconcile.
+func applyUndrifted(ctx context.Context, c client.Client, obj client.Object, fields []string) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	undrifted := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(content)}
+
+	for _, field := range fields {
+		if !removeField(undrifted.Object, field) {
+			log.FromContext(ctx).Info("Leaving drifted object as it is", "field", field)
+			return nil
+		}
+	}
+
+	err = c.Patch(ctx, undrifted, client.Apply, client.FieldOwner(fieldManager))
+	if apierrors.IsConflict(err) {
+		return nil
+	}
+
+	return err
+}
+
+// upgradeManagedFields hands the fields of obj owned by legacyFieldManagers
+// over to fieldManager.
+func upgradeManagedFields(ctx context.Context, c client.Client, obj client.Object) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(obj, legacyFieldManagers, fieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+
+	if err := c.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("upgrading managed fields of %q: %w", obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// apply writes obj with server-side apply, recording conflicts and drift on
+// the app.
+func (r *VirtoolAppReconciler) apply(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	obj client.Object,
+) (controllerutil.OperationResult, error) {
+	drift := &driftTracker{policy: app.Spec.DriftPolicy, records: &app.Status.Drift}
+	return applyObject(ctx, r.Client, r.Recorder, app, obj, drift)
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Component labels of the Jobs that take, restore and prune backups.
+const (
+	backupComponent  = "backup"
+	restoreComponent = "restore"
+	pruneComponent   = "prune"
+)
+
+// backupMountPath is where the backup target is mounted in backup Jobs.
+const backupMountPath = "/backup"
+
+// defaultUploadImage provides the AWS CLI used to transfer backups to and
+// from object storage.
+const defaultUploadImage = "amazon/aws-cli:2.13.0"
+
+// pruneImage provides the shell used to delete backups from a persistent
+// volume claim.
+const pruneImage = "busybox:1.36"
+
+// pruneJobTTL is how long finished prune Jobs are kept.
+const pruneJobTTL int32 = 3600
+
+// transfer is the direction backups move between the scratch volume of a
+// Job and an object storage target.
+type transfer int
+
+const (
+	transferUpload transfer = iota
+	transferDownload
+)
+
+// backupDatabases returns the datastores of the app that are backed up.
+// Redis only holds transient state and is never included.
+func backupDatabases(ctx context.Context, c client.Reader, app *virtoolv1beta1.VirtoolApp) ([]string, error) {
+	var databases []string
+
+	for _, name := range []string{connectionKeyPostgres, connectionKeyMongoDB} {
+		_, err := connectionString(ctx, c, app, name)
+		if errors.Is(err, errConnectionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+
+	return databases, nil
+}
+
+// renderBackupJob builds the Job that dumps the given databases. Dumps are
+// written straight to a persistent volume claim target. For object storage
+// targets they are written to a scratch volume and uploaded once all dumps
+// have finished.
+func renderBackupJob(
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	id string,
+	databases []string,
+) (*batchv1.Job, error) {
+	directory := backupDirectory(id)
+
+	dumps := make([]corev1.Container, 0, len(databases))
+	for _, name := range databases {
+		container := databaseContainer(app, policy, name, directory)
+		container.Command = []string{"sh", "-c", `mkdir -p "$BACKUP_DIR" && ` + dumpScripts[name]}
+		dumps = append(dumps, container)
+	}
+
+	return renderTransferJob(app, policy, id, id, backupComponent, dumps, transferUpload)
+}
+
+// renderRestoreJob builds the Job named name that restores the given
+// databases from a backup, replacing their current contents.
+func renderRestoreJob(
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	name, id string,
+	databases []string,
+) (*batchv1.Job, error) {
+	directory := backupDirectory(id)
+
+	restores := make([]corev1.Container, 0, len(databases))
+	for _, database := range databases {
+		container := databaseContainer(app, policy, database, directory)
+		container.Command = []string{"sh", "-c", restoreScripts[database]}
+		restores = append(restores, container)
+	}
+
+	return renderTransferJob(app, policy, name, id, restoreComponent, restores, transferDownload)
+}
+
+// renderPruneJob builds the Job named name that deletes a backup from the
+// target.
+func renderPruneJob(
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	name, id string,
+) (*batchv1.Job, error) {
+	var container corev1.Container
+
+	switch target := policy.Target; {
+	case target.PersistentVolumeClaim != nil:
+		container = corev1.Container{
+			Name:         pruneComponent,
+			Image:        pruneImage,
+			Command:      []string{"rm", "-rf", backupDirectory(id)},
+			VolumeMounts: []corev1.VolumeMount{{Name: backupComponent, MountPath: backupMountPath}},
+		}
+		job, err := renderTransferJob(app, policy, name, id, pruneComponent, []corev1.Container{container}, transferUpload)
+		if err != nil {
+			return nil, err
+		}
+		ttl := pruneJobTTL
+		job.Spec.TTLSecondsAfterFinished = &ttl
+		return job, nil
+
+	case target.ObjectStorage != nil:
+		container = objectStorageContainer(target.ObjectStorage, "rm", "--recursive", objectStorageURL(target.ObjectStorage, id))
+		container.Name = pruneComponent
+		container.VolumeMounts = nil
+
+		labels := appLabels(app)
+		labels[labelComponent] = pruneComponent
+		ttl := pruneJobTTL
+
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace, Labels: labels},
+			Spec: batchv1.JobSpec{
+				TTLSecondsAfterFinished: &ttl,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						RestartPolicy: corev1.RestartPolicyNever,
+						Containers:    []corev1.Container{container},
+					},
+				},
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("backup policy of %q has no target", app.Name)
+}
+
+// renderTransferJob builds a Job running containers against the backup
+// target of a policy. Persistent volume claim targets are mounted directly.
+// Object storage targets are staged through a scratch volume, uploading the
+// backup after the containers finish or downloading it before they start.
+func renderTransferJob(
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	name, id, component string,
+	containers []corev1.Container,
+	direction transfer,
+) (*batchv1.Job, error) {
+	podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+	directory := backupDirectory(id)
+
+	switch target := policy.Target; {
+	case target.PersistentVolumeClaim != nil:
+		podSpec.Containers = containers
+		podSpec.Volumes = []corev1.Volume{{
+			Name: backupComponent,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: target.PersistentVolumeClaim.ClaimName,
+				},
+			},
+		}}
+
+	case target.ObjectStorage != nil:
+		url := objectStorageURL(target.ObjectStorage, id)
+		if direction == transferUpload {
+			podSpec.InitContainers = containers
+			podSpec.Containers = []corev1.Container{
+				objectStorageContainer(target.ObjectStorage, "cp", "--recursive", directory, url),
+			}
+		} else {
+			podSpec.InitContainers = []corev1.Container{
+				objectStorageContainer(target.ObjectStorage, "cp", "--recursive", url, directory),
+			}
+			podSpec.Containers = containers
+		}
+		podSpec.Volumes = []corev1.Volume{{
+			Name:         backupComponent,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}}
+
+	default:
+		return nil, fmt.Errorf("backup policy of %q has no target", app.Name)
+	}
+
+	labels := appLabels(app)
+	labels[labelComponent] = component
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{versionAnnotation: app.Status.CurrentVersion},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       podSpec,
+			},
+		},
+	}, nil
+}
+
+// dumpScripts and restoreScripts hold the shell commands that dump and
+// restore each database.
+var (
+	dumpScripts = map[string]string{
+		connectionKeyPostgres: `pg_dump --format=custom --dbname="$CONNECTION_STRING" --file="$BACKUP_DIR/postgres.dump"`,
+		connectionKeyMongoDB:  `mongodump --uri="$CONNECTION_STRING" --gzip --archive="$BACKUP_DIR/mongodb.archive.gz"`,
+	}
+	restoreScripts = map[string]string{
+		connectionKeyPostgres: `pg_restore --clean --if-exists --no-owner --dbname="$CONNECTION_STRING" "$BACKUP_DIR/postgres.dump"`,
+		connectionKeyMongoDB:  `mongorestore --uri="$CONNECTION_STRING" --drop --gzip --archive="$BACKUP_DIR/mongodb.archive.gz"`,
+	}
+)
+
+// databaseContainer returns a container with the client tools of the named
+// database, its connection string and the backup directory.
+func databaseContainer(
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	name, directory string,
+) corev1.Container {
+	var image string
+
+	switch name {
+	case connectionKeyPostgres:
+		image = policy.PostgresImage
+	case connectionKeyMongoDB:
+		image = policy.MongoDBImage
+	}
+
+	if image == "" {
+		for i := range dependencyKinds {
+			if dependencyKinds[i].name == name {
+				image = dependencyKinds[i].image
+			}
+		}
+	}
+
+	return corev1.Container{
+		Name:  name,
+		Image: image,
+		Env: []corev1.EnvVar{
+			connectionEnv(app, "CONNECTION_STRING", name),
+			{Name: "BACKUP_DIR", Value: directory},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: backupComponent, MountPath: backupMountPath}},
+	}
+}
+
+// objectStorageContainer returns a container running an AWS CLI s3 command
+// against an object storage target.
+func objectStorageContainer(target *virtoolv1beta1.ObjectStorageTarget, args ...string) corev1.Container {
+	image := target.Image
+	if image == "" {
+		image = defaultUploadImage
+	}
+
+	args = append([]string{"s3"}, args...)
+	if target.Endpoint != "" {
+		args = append(args, "--endpoint-url", target.Endpoint)
+	}
+
+	return corev1.Container{
+		Name:    "transfer",
+		Image:   image,
+		Command: []string{"aws"},
+		Args:    args,
+		EnvFrom: []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: target.CredentialsSecret},
+		}},
+		VolumeMounts: []corev1.VolumeMount{{Name: backupComponent, MountPath: backupMountPath}},
+	}
+}
+
+// objectStorageURL returns the URL a backup is stored under.
+func objectStorageURL(target *virtoolv1beta1.ObjectStorageTarget, id string) string {
+	return strings.TrimSuffix(target.URL, "/") + "/" + id
+}
+
+// backupDirectory returns the directory a backup is written to in a Job.
+func backupDirectory(id string) string {
+	return backupMountPath + "/" + id
+}
+
+// timestampedName returns prefix followed by the time with second precision,
+// in a form that is valid as an object name.
+func timestampedName(prefix string, t time.Time) string {
+	return prefix + "-" + t.UTC().Format("20060102-150405")
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxDriftRecords is the number of drift records kept in a status.
+const maxDriftRecords = 10
+
+// conflictManager matches the field manager in the message of a conflict
+// cause returned by server-side apply.
+var conflictManager = regexp.MustCompile(`^conflict with "([^"]*)"`)
+
+// driftTracker records the drift of the objects an owner manages in the
+// owner's status. Drift is a field the operator applies having been changed
+// by another field manager since, which server-side apply reports as a
+// conflict.
+type driftTracker struct {
+	policy  virtoolv1beta1.DriftPolicy
+	records *[]virtoolv1beta1.DriftRecord
+}
+
+// reportOnly reports whether drifted objects are left as they are.
+func (t *driftTracker) reportOnly() bool {
+	return t != nil && t.policy == virtoolv1beta1.DriftPolicyReportOnly
+}
+
+// record records that fields of an object were changed by managers. The
+// record is closed with a correction time if corrected is set. It reports
+// whether the drift is news, being either new or corrected or having spread
+// to other fields.
+func (t *driftTracker) record(kind, name string, fields, managers []string, corrected *metav1.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	if record := t.open(kind, name); record != nil {
+		news := corrected != nil || !equalStrings(record.Fields, fields)
+		record.Fields = fields
+		record.Managers = managers
+		record.CorrectionTime = corrected
+		return news
+	}
+
+	*t.records = append(*t.records, virtoolv1beta1.DriftRecord{
+		Kind:           kind,
+		Name:           name,
+		Fields:         fields,
+		Managers:       managers,
+		DetectionTime:  metav1.Now(),
+		CorrectionTime: corrected,
+	})
+	if excess := len(*t.records) - maxDriftRecords; excess > 0 {
+		*t.records = (*t.records)[excess:]
+	}
+
+	return true
+}
+
+// resolve removes the open record of an object that no longer conflicts with
+// another manager, its drift having been reverted by someone else.
+func (t *driftTracker) resolve(kind, name string) {
+	if t == nil || t.open(kind, name) == nil {
+		return
+	}
+
+	kept := (*t.records)[:0]
+	for _, record := range *t.records {
+		if record.Kind != kind || record.Name != name || record.CorrectionTime != nil {
+			kept = append(kept, record)
+		}
+	}
+	*t.records = kept
+}
+
+// open returns the record of an object's drift that has not been corrected
+// yet, or nil if there is none.
+func (t *driftTracker) open(kind, name string) *virtoolv1beta1.DriftRecord {
+	for i := range *t.records {
+		record := &(*t.records)[i]
+		if record.Kind == kind && record.Name == name && record.CorrectionTime == nil {
+			return record
+		}
+	}
+	return nil
+}
+
+// conflictingFields returns the paths of the fields of a server-side apply
+// conflict and the managers they conflict with.
+func conflictingFields(err error) ([]string, []string) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil, nil
+	}
+
+	var fields []string
+	managers := make(map[string]struct{})
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		fields = append(fields, cause.Field)
+		if match := conflictManager.FindStringSubmatch(cause.Message); match != nil {
+			managers[match[1]] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(managers))
+	for name := range managers {
+		names = append(names, name)
+	}
+	sort.Strings(fields)
+	sort.Strings(names)
+
+	return fields, names
+}
+
+// setComponentDrift replaces the drift recorded in the app's status for the
+// workloads of its components with the drift recorded by the components.
+func setComponentDrift(app *virtoolv1beta1.VirtoolApp, children []*virtoolv1beta1.VirtoolComponent) {
+	records := make([]virtoolv1beta1.DriftRecord, 0, len(app.Status.Drift))
+	for _, record := range app.Status.Drift {
+		if record.Kind != "Deployment" {
+			records = append(records, record)
+		}
+	}
+	for _, child := range children {
+		records = append(records, child.Status.Drift...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].DetectionTime.Before(&records[j].DetectionTime)
+	})
+	if excess := len(records) - maxDriftRecords; excess > 0 {
+		records = records[excess:]
+	}
+	if len(records) == 0 {
+		records = nil
+	}
+
+	app.Status.Drift = records
+}
+
+// rolloutFields are the paths, by kind, of the fields a rollout changes to
+// run a new version: the pod templates of component workloads and the version
+// and image of their VirtoolComponents.
+var rolloutFields = map[string][]string{
+	"Deployment":       {".spec.template"},
+	"VirtoolComponent": {".spec.version", ".spec.image"},
+}
+
+// rolloutBlockingObjects returns the objects of an app with drifted fields
+// that are left as they are by the ReportOnly drift policy and that a rollout
+// would overwrite. Drift in other fields is only reported.
+func rolloutBlockingObjects(app *virtoolv1beta1.VirtoolApp) []string {
+	var objects []string
+	for _, record := range app.Status.Drift {
+		if record.CorrectionTime == nil && overwrittenByRollout(record.Kind, record.Fields) {
+			objects = append(objects, record.Kind+" "+record.Name)
+		}
+	}
+	return objects
+}
+
+// overwrittenByRollout reports whether any of the fields of an object of the
+// given kind is changed by a rollout.
+func overwrittenByRollout(kind string, fields []string) bool {
+	for _, field := range fields {
+		for _, prefix := range rolloutFields[kind] {
+			if field == prefix || strings.HasPrefix(field, prefix+".") || strings.HasPrefix(field, prefix+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathElement is an element of the path of a field in a server-side apply
+// conflict, such as .spec or [name="virtool"]. A list item is selected by its
+// keys, its value or its index.
+type pathElement struct {
+	field string
+	keys  map[string]interface{}
+	value interface{}
+	index int
+}
+
+// parseFieldPath parses the path of a field as it is reported in a conflict,
+// such as .spec.template.spec.containers[name="virtool"].image.
+func parseFieldPath(path string) ([]pathElement, error) {
+	var elements []pathElement
+	for rest := path; rest != ""; {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			elements = append(elements, pathElement{field: rest[1:end]})
+			rest = rest[end:]
+
+		case '[':
+			end := closingBracket(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated list item in %q", path)
+			}
+			element, err := parseListItem(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("parsing %q: %w", path, err)
+			}
+			elements = append(elements, element)
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", rest[0], path)
+		}
+	}
+
+	return elements, nil
+}
+
+// closingBracket returns the index of the bracket closing the list item at the
+// start of s, skipping brackets in quoted values.
+func closingBracket(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseListItem parses the selector of a list item: key=value pairs, a
+// =value of a set or an index.
+func parseListItem(s string) (pathElement, error) {
+	if index, err := strconv.Atoi(s); err == nil {
+		return pathElement{index: index}, nil
+	}
+
+	element := pathElement{index: -1}
+	if strings.HasPrefix(s, "=") {
+		if err := json.Unmarshal([]byte(s[1:]), &element.value); err != nil {
+			return pathElement{}, err
+		}
+		return element, nil
+	}
+
+	element.keys = make(map[string]interface{})
+	// Each value is JSON, and is followed by a comma if more keys follow.
+	for rest := s; rest != ""; {
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return pathElement{}, fmt.Errorf("list item %q has no value", s)
+		}
+		decoder := json.NewDecoder(strings.NewReader(value))
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return pathElement{}, err
+		}
+		element.keys[name] = decoded
+		rest = strings.TrimPrefix(value[decoder.InputOffset():], ",")
+	}
+
+	return element, nil
+}
+
+// removeField removes the field at path from the content of an object, and
+// reports whether it was found.
+func removeField(content map[string]interface{}, path string) bool {
+	elements, err := parseFieldPath(path)
+	if err != nil || len(elements) == 0 {
+		return false
+	}
+
+	_, removed := removeElements(content, elements)
+	return removed
+}
+
+// removeElements removes the value at the path of elements from value and
+// returns the value left.
+func removeElements(value interface{}, elements []pathElement) (interface{}, bool) {
+	element, last := elements[0], len(elements) == 1
+
+	if element.field != "" {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, false
+		}
+		child, ok := object[element.field]
+		if !ok {
+			return value, false
+		}
+		if last {
+			delete(object, element.field)
+			return object, true
+		}
+		child, removed := removeElements(child, elements[1:])
+		object[element.field] = child
+		return object, removed
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return value, false
+	}
+	for i, item := range items {
+		if !element.matches(i, item) {
+			continue
+		}
+		if last {
+			return append(items[:i:i], items[i+1:]...), true
+		}
+		child, removed := removeElements(item, elements[1:])
+		items[i] = child
+		return items, removed
+	}
+
+	return value, false
+}
+
+// matches reports whether the i-th item of a list is the one the element
+// selects.
+func (e pathElement) matches(i int, item interface{}) bool {
+	switch {
+	case e.keys != nil:
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for name, value := range e.keys {
+			if !equalJSON(object[name], value) {
+				return false
+			}
+		}
+		return true
+	case e.index < 0:
+		return equalJSON(item, e.value)
+	default:
+		return i == e.index
+	}
+}
+
+// equalJSON reports whether two values encode to the same JSON, as numbers in
+// objects are int64 where decoded ones are float64.
+func equalJSON(a, b interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
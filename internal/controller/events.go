@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reasons of the events recorded on a VirtoolApp. Events that report a
+// condition changing use the reason of the condition instead.
+const (
+	eventUpgradeStarted    = "UpgradeStarted"
+	eventUpgradeCompleted  = "UpgradeCompleted"
+	eventUpgradeRolledBack = "UpgradeRolledBack"
+	eventJobLaunched       = "JobLaunched"
+	eventBackupStarted     = "BackupStarted"
+	eventDriftCorrected    = "DriftCorrected"
+	eventSuspended         = "Suspended"
+	eventResumed           = "Resumed"
+	eventApplyConflict     = "ApplyConflict"
+	eventDriftDetected     = "DriftDetected"
+	eventAdopted           = "Adopted"
+	eventDigestChanged     = "DigestChanged"
+)
+
+// blockingReasons are the reasons of an Upgrading condition that mean the
+// upgrade cannot continue without intervention.
+var blockingReasons = map[string]bool{
+	"UnsupportedUpgrade":     true,
+	"IncompatibleComponents": true,
+	"UpdateJobFailed":        true,
+	"BackupFailed":           true,
+	"DriftBlocking":          true,
+}
+
+// event records an event on the app. It does nothing if the reconciler has
+// no recorder.
+func (r *VirtoolAppReconciler) event(
+	app *virtoolv1beta1.VirtoolApp,
+	eventType, reason, messageFmt string,
+	args ...interface{},
+) {
+	if r.Recorder == nil {
+		return
+	}
+
+	r.Recorder.Eventf(app, eventType, reason, messageFmt, args...)
+}
+
+// recordTransitions records events for the conditions that changed during a
+// reconcile: the app being suspended for a restore or resumed, datastores
+// becoming unavailable or available again, pods of a component running more
+// than one version for too long and upgrades becoming blocked.
+func (r *VirtoolAppReconciler) recordTransitions(app *virtoolv1beta1.VirtoolApp, original *virtoolv1beta1.VirtoolAppStatus) {
+	previous := func(conditionType string) *metav1.Condition {
+		return meta.FindStatusCondition(original.Conditions, conditionType)
+	}
+
+	before := previous(virtoolv1beta1.ConditionReady)
+	after := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionReady)
+	restoringBefore := before != nil && before.Reason == "Restoring"
+	restoringAfter := after != nil && after.Reason == "Restoring"
+	switch {
+	case restoringAfter && !restoringBefore:
+		r.event(app, corev1.EventTypeNormal, eventSuspended, "%s", after.Message)
+	case restoringBefore && !restoringAfter:
+		r.event(app, corev1.EventTypeNormal, eventResumed, "Components are running again")
+	}
+
+	before = previous(virtoolv1beta1.ConditionDependenciesReady)
+	after = meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionDependenciesReady)
+	if after != nil && (before == nil || before.Status != after.Status) {
+		// Datastores being available is only news if they were not before.
+		if after.Status != metav1.ConditionTrue {
+			r.event(app, corev1.EventTypeWarning, after.Reason, "%s", after.Message)
+		} else if before != nil {
+			r.event(app, corev1.EventTypeNormal, after.Reason, "%s", after.Message)
+		}
+	}
+
+	before = previous(virtoolv1beta1.ConditionVersionSkew)
+	after = meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionVersionSkew)
+	if after != nil && (before == nil || before.Status != after.Status) {
+		// Pods running a single version is only news if they did not before.
+		if after.Status == metav1.ConditionTrue {
+			r.event(app, corev1.EventTypeWarning, after.Reason, "%s", after.Message)
+		} else if before != nil {
+			r.event(app, corev1.EventTypeNormal, after.Reason, "%s", after.Message)
+		}
+	}
+
+	if blocked := upgradeBlocked(app, original); blocked != nil {
+		r.event(app, corev1.EventTypeWarning, blocked.Reason, "%s", blocked.Message)
+	}
+}
+
+// upgradeBlocked returns the Upgrading condition of the app if the upgrade
+// became blocked during a reconcile, or nil otherwise.
+func upgradeBlocked(app *virtoolv1beta1.VirtoolApp, original *virtoolv1beta1.VirtoolAppStatus) *metav1.Condition {
+	before := meta.FindStatusCondition(original.Conditions, virtoolv1beta1.ConditionUpgrading)
+	after := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+
+	if after == nil || !blockingReasons[after.Reason] {
+		return nil
+	}
+	if before != nil && before.Reason == after.Reason && before.Message == after.Message {
+		return nil
+	}
+
+	return after
+}
+
+// recordDrift records that an object was changed back to its desired state.
+// Updates only count as drift when the spec of the app has not changed since
+// it was last reconciled, so the object must have been changed by something
+// else.
+func (r *VirtoolAppReconciler) recordDrift(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	result controllerutil.OperationResult,
+	kind, name string,
+) {
+	if result != controllerutil.OperationResultUpdated || app.Status.ObservedGeneration != app.Generation {
+		return
+	}
+
+	log.FromContext(ctx).Info("Corrected drift", "kind", kind, "name", name)
+	r.event(app, corev1.EventTypeNormal, eventDriftCorrected, "Reverted changes to %s %s", kind, name)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+const (
+	// ownerIndex indexes the objects a VirtoolApp controls by the app's name.
+	ownerIndex = ".metadata.controller"
+
+	// appIndex indexes the pods of a VirtoolApp by the app's name. Pods are
+	// controlled by ReplicaSets and StatefulSets rather than the app, so they
+	// are matched on the labels they inherit from their templates instead.
+	appIndex = ".metadata.labels.app"
+)
+
+// ownedTypes are the kinds of object a VirtoolApp controls.
+func ownedTypes() []client.Object {
+	return []client.Object{
+		&virtoolv1beta1.VirtoolComponent{},
+		&appsv1.StatefulSet{},
+		&batchv1.Job{},
+		&corev1.Service{},
+		&policyv1.PodDisruptionBudget{},
+	}
+}
+
+// indexOwner returns the name of the VirtoolApp controlling obj, if any.
+func indexOwner(obj client.Object) []string {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.APIVersion != virtoolv1beta1.GroupVersion.String() || owner.Kind != "VirtoolApp" {
+		return nil
+	}
+	return []string{owner.Name}
+}
+
+// indexApp returns the name of the VirtoolApp whose operator-managed labels
+// obj carries, if any.
+func indexApp(obj client.Object) []string {
+	objLabels := obj.GetLabels()
+	if objLabels[labelManagedBy] != managerName || objLabels[labelInstance] == "" {
+		return nil
+	}
+	return []string{objLabels[labelInstance]}
+}
+
+// setupIndexes registers the field indexes used to list the objects
+// belonging to a VirtoolApp.
+func setupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, obj := range ownedTypes() {
+		if err := indexer.IndexField(ctx, obj, ownerIndex, indexOwner); err != nil {
+			return fmt.Errorf("indexing %T by owner: %w", obj, err)
+		}
+	}
+
+	if err := indexer.IndexField(ctx, &corev1.Pod{}, appIndex, indexApp); err != nil {
+		return fmt.Errorf("indexing pods by app: %w", err)
+	}
+
+	return nil
+}
+
+// ownedListOptions returns the options for listing the objects controlled by
+// app. The owner index is only used once it has been registered, as clients
+// reading directly from the API server cannot select on it.
+func (r *VirtoolAppReconciler) ownedListOptions(app *virtoolv1beta1.VirtoolApp) []client.ListOption {
+	opts := []client.ListOption{
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels(appLabels(app)),
+	}
+	if r.indexed {
+		opts = append(opts, client.MatchingFields{ownerIndex: app.Name})
+	}
+	return opts
+}
+
+// podListOptions returns the options for listing the pods of app that carry
+// podLabels.
+func (r *VirtoolAppReconciler) podListOptions(app *virtoolv1beta1.VirtoolApp, podLabels map[string]string) []client.ListOption {
+	opts := []client.ListOption{
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels(podLabels),
+	}
+	if r.indexed {
+		opts = append(opts, client.MatchingFields{appIndex: app.Name})
+	}
+	return opts
+}
+
+// CacheByObject restricts the manager's informers for the kinds the operator
+// creates to objects carrying its managed-by label, so that unrelated objects
+// in the cluster are neither watched nor held in memory. Secrets created by
+// users for apps are not in the cache and are read from the API server.
+func CacheByObject() map[client.Object]cache.ByObject {
+	managed := labels.SelectorFromSet(labels.Set{labelManagedBy: managerName})
+
+	// Deployments are controlled by VirtoolComponents rather than the app.
+	byObject := map[client.Object]cache.ByObject{
+		&corev1.Pod{}:        {Label: managed},
+		&appsv1.Deployment{}: {Label: managed},
+		&corev1.Secret{}:     {Label: managed},
+	}
+	for _, obj := range ownedTypes() {
+		byObject[obj] = cache.ByObject{Label: managed}
+	}
+	return byObject
+}
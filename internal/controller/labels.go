@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+)
+
+const (
+	labelName      = "app.kubernetes.io/name"
+	labelInstance  = "app.kubernetes.io/instance"
+	labelComponent = "app.kubernetes.io/component"
+	labelManagedBy = "app.kubernetes.io/managed-by"
+
+	appName     = "virtool"
+	managerName = "virtool-operator"
+)
+
+// appLabels returns the labels shared by every object the operator manages
+// for the given VirtoolApp.
+func appLabels(app *virtoolv1beta1.VirtoolApp) map[string]string {
+	return map[string]string{
+		labelName:      appName,
+		labelInstance:  app.Name,
+		labelManagedBy: managerName,
+	}
+}
+
+// componentLabels returns the labels identifying objects belonging to a single
+// component of the given VirtoolApp.
+func componentLabels(app *virtoolv1beta1.VirtoolApp, component *virtoolv1beta1.ComponentSpec) map[string]string {
+	return labelsFor(app.Name, component.Name)
+}
+
+// labelsFor returns the labels identifying objects belonging to the named
+// component of the named VirtoolApp.
+func labelsFor(instance, component string) map[string]string {
+	return map[string]string{
+		labelName:      appName,
+		labelInstance:  instance,
+		labelComponent: component,
+		labelManagedBy: managerName,
+	}
+}
+
+// selectorLabels returns the subset of componentLabels used to select the pods
+// of a component. Selectors are immutable on some workloads, so this set must
+// not change once objects have been created.
+func selectorLabels(app *virtoolv1beta1.VirtoolApp, component *virtoolv1beta1.ComponentSpec) map[string]string {
+	return selectorLabelsFor(app.Name, component.Name)
+}
+
+// selectorLabelsFor returns the selectorLabels of the named component of the
+// named VirtoolApp.
+func selectorLabelsFor(instance, component string) map[string]string {
+	return map[string]string{
+		labelName:      appName,
+		labelInstance:  instance,
+		labelComponent: component,
+	}
+}
+
+// componentObjectName returns the name used for objects owned by a component.
+func componentObjectName(app *virtoolv1beta1.VirtoolApp, component *virtoolv1beta1.ComponentSpec) string {
+	return app.Name + "-" + component.Name
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
+)
+
+// upgradeFailureReasons are the reasons of a blocked upgrade that count as a
+// failed upgrade rather than one that was refused before it started.
+var upgradeFailureReasons = map[string]bool{
+	"UpdateJobFailed": true,
+	"BackupFailed":    true,
+}
+
+// recordAppMetrics updates the version and component metrics of the app and
+// counts an upgrade that failed during the reconcile.
+func recordAppMetrics(app *virtoolv1beta1.VirtoolApp, original *virtoolv1beta1.VirtoolAppStatus) {
+	labels := metrics.AppLabels(app.Namespace, app.Name)
+	status := &app.Status
+
+	metrics.AppInfo.DeletePartialMatch(labels)
+	metrics.AppInfo.WithLabelValues(app.Namespace, app.Name, status.CurrentVersion, app.Spec.Version).Set(1)
+
+	upgrading := 0.0
+	if status.TargetVersion != "" {
+		upgrading = 1
+	}
+	metrics.AppUpgrading.WithLabelValues(app.Namespace, app.Name).Set(upgrading)
+
+	// Components that were removed or changed version leave stale series
+	// behind unless they are cleared first.
+	metrics.ComponentReadyReplicas.DeletePartialMatch(labels)
+	metrics.ComponentReady.DeletePartialMatch(labels)
+	metrics.ComponentInfo.DeletePartialMatch(labels)
+
+	for _, component := range status.ComponentsStatus {
+		ready := 0.0
+		if component.Status == virtoolv1beta1.ComponentStatusReady {
+			ready = 1
+		}
+
+		metrics.ComponentReadyReplicas.WithLabelValues(app.Namespace, app.Name, component.Name).
+			Set(float64(component.ReadyReplicas))
+		metrics.ComponentReady.WithLabelValues(app.Namespace, app.Name, component.Name).Set(ready)
+		metrics.ComponentInfo.WithLabelValues(app.Namespace, app.Name, component.Name, component.CurrentVersion).Set(1)
+	}
+
+	if blocked := upgradeBlocked(app, original); blocked != nil && upgradeFailureReasons[blocked.Reason] {
+		metrics.UpgradesTotal.WithLabelValues(app.Namespace, app.Name, metrics.OutcomeFailed).Inc()
+	}
+}
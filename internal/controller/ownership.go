@@ -0,0 +1,39 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// controlledObjects returns the items of list that are controlled by owner.
+func controlledObjects(list client.ObjectList, owner metav1.Object) ([]client.Object, error) {
+	var objects []client.Object
+
+	err := meta.EachListItem(list, func(item runtime.Object) error {
+		obj, ok := item.(client.Object)
+		if ok && metav1.IsControlledBy(obj, owner) {
+			objects = append(objects, obj)
+		}
+		return nil
+	})
+
+	return objects, err
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/bryce-davidson/virtool-operator/internal/controller"
+
+// Attributes of the spans recorded by the controller.
+const (
+	attrNamespace     = attribute.Key("k8s.namespace.name")
+	attrApp           = attribute.Key("virtool.app")
+	attrVersion       = attribute.Key("virtool.version")
+	attrTargetVersion = attribute.Key("virtool.target_version")
+	attrUpgradePath   = attribute.Key("virtool.upgrade.path")
+	attrUpgradeHop    = attribute.Key("virtool.upgrade.hop")
+	attrUpgradeStage  = attribute.Key("virtool.upgrade.stage")
+	attrAdvanced      = attribute.Key("virtool.upgrade.advanced")
+	attrJob           = attribute.Key("k8s.job.name")
+	attrJobPhase      = attribute.Key("virtool.job.phase")
+)
+
+// traceParentKey is the carrier key of the W3C trace context.
+const traceParentKey = "traceparent"
+
+// tracer returns the tracer of the reconciler, falling back to the global
+// tracer provider.
+func (r *VirtoolAppReconciler) tracer() trace.Tracer {
+	provider := r.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return provider.Tracer(tracerName)
+}
+
+// startReconcileSpan starts the span of a reconcile. While an upgrade is in
+// progress the span joins the trace of the upgrade recorded in the status.
+func (r *VirtoolAppReconciler) startReconcileSpan(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+) (context.Context, trace.Span) {
+	if traceParent := app.Status.UpgradeTraceParent; traceParent != "" {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{traceParentKey: traceParent})
+	}
+
+	return r.tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attrNamespace.String(app.Namespace),
+		attrApp.String(app.Name),
+		attrVersion.String(app.Status.CurrentVersion),
+		attrTargetVersion.String(app.Spec.Version),
+	))
+}
+
+// startUpgradeTrace begins the trace of a new upgrade attempt and records it in
+// the status so that later reconciles join it. The trace is linked to the
+// reconcile that planned the upgrade. It returns a context in which the rest
+// of the reconcile is traced as part of the upgrade.
+func (r *VirtoolAppReconciler) startUpgradeTrace(ctx context.Context, app *virtoolv1beta1.VirtoolApp) context.Context {
+	ctx, span := r.tracer().Start(ctx, "Upgrade",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attrNamespace.String(app.Namespace),
+			attrApp.String(app.Name),
+			attrVersion.String(app.Status.CurrentVersion),
+			attrTargetVersion.String(app.Spec.Version),
+			attrUpgradePath.StringSlice(app.Status.UpgradePath),
+		))
+	span.End()
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	app.Status.UpgradeTraceParent = carrier[traceParentKey]
+
+	return ctx
+}
+
+// startStageSpan starts the span of the work done on an upgrade stage in a
+// reconcile.
+func (r *VirtoolAppReconciler) startStageSpan(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+) (context.Context, trace.Span) {
+	status := &app.Status
+
+	return r.tracer().Start(ctx, "Upgrade"+string(status.UpgradeStage), trace.WithAttributes(
+		attrUpgradeStage.String(string(status.UpgradeStage)),
+		attrVersion.String(status.CurrentVersion),
+		attrTargetVersion.String(status.TargetVersion),
+		attrUpgradeHop.Int(int(status.CompletedHops)+1),
+	))
+}
+
+// endSpan ends a span, marking it as failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// addSpanEvent records an event on the span of the context.
+func addSpanEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+}
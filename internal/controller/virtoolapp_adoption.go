@@ -0,0 +1,363 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/image"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// adoptionError reports why the existing objects of a component cannot be
+// adopted. It is shown to users in the app's Ready condition.
+type adoptionError string
+
+func (e adoptionError) Error() string {
+	return string(e)
+}
+
+// reconcileAdoption checks that the Deployments and Services adopted by the
+// app's components exist and, for an app that has not been deployed yet,
+// infers its current version from the image tags of the adopted pods. The
+// adopted components are then rolled out by the usual upgrade logic, which
+// finds them already running the current version.
+//
+// It reports false, with the reason in the Ready condition, if the objects
+// cannot be adopted.
+func (r *VirtoolAppReconciler) reconcileAdoption(ctx context.Context, app *virtoolv1beta1.VirtoolApp) (bool, error) {
+	versions := make(map[string][]string)
+
+	components := resolveComponents(app)
+	for i := range components {
+		component := &components[i]
+		if component.Adopt == nil || !runsAsDeployment(component) {
+			continue
+		}
+
+		deployment, err := r.adoptedDeployment(ctx, app, component)
+		if err == nil {
+			_, err = r.adoptedService(ctx, app, component)
+		}
+		if err == nil && app.Status.CurrentVersion == "" {
+			var version string
+			if version, err = r.runningVersion(ctx, deployment); err == nil {
+				versions[version] = append(versions[version], deployment.Name)
+			}
+		}
+
+		var adoptionErr adoptionError
+		if errors.As(err, &adoptionErr) {
+			setCondition(app, virtoolv1beta1.ConditionReady, metav1.ConditionFalse, "AdoptionFailed", adoptionErr.Error())
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(versions) > 1 {
+		running := make([]string, 0, len(versions))
+		for version, names := range versions {
+			running = append(running, fmt.Sprintf("%s (%s)", version, strings.Join(names, ", ")))
+		}
+		sort.Strings(running)
+		setCondition(app, virtoolv1beta1.ConditionReady, metav1.ConditionFalse, "AdoptionFailed",
+			"Adopted Deployments run different versions: "+strings.Join(running, ", "))
+		return false, nil
+	}
+
+	for version, names := range versions {
+		log.FromContext(ctx).Info("Adopted existing deployments", "version", version, "deployments", names)
+		r.event(app, corev1.EventTypeNormal, eventAdopted,
+			"Adopted Deployments %s running version %s", strings.Join(names, ", "), version)
+		app.Status.CurrentVersion = version
+	}
+
+	return true, nil
+}
+
+// adoptedDeployment returns the existing Deployment a component adopts, or
+// nil if it does not adopt one.
+func (r *VirtoolAppReconciler) adoptedDeployment(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+) (*appsv1.Deployment, error) {
+	adopt := component.Adopt
+	if adopt == nil {
+		return nil, nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if adopt.DeploymentName != "" {
+		found, err := r.getAdoptable(ctx, types.NamespacedName{Name: adopt.DeploymentName, Namespace: app.Namespace}, deployment)
+		if err != nil {
+			return nil, fmt.Errorf("getting deployment %q: %w", adopt.DeploymentName, err)
+		}
+		if !found {
+			return nil, adoptionError(fmt.Sprintf("Deployment %s adopted by component %s does not exist",
+				adopt.DeploymentName, component.Name))
+		}
+	} else {
+		var deploymentList appsv1.DeploymentList
+		if err := r.listAdopted(ctx, app, component, &deploymentList); err != nil {
+			return nil, fmt.Errorf("listing deployments: %w", err)
+		}
+		if len(deploymentList.Items) != 1 {
+			return nil, adoptionError(fmt.Sprintf("%d Deployments match the selector of component %s, expected 1",
+				len(deploymentList.Items), component.Name))
+		}
+		deployment = &deploymentList.Items[0]
+	}
+
+	if owner := metav1.GetControllerOf(deployment); owner != nil &&
+		(owner.Kind != "VirtoolComponent" || owner.Name != componentObjectName(app, component)) {
+		return nil, adoptionError(fmt.Sprintf("Deployment %s is already controlled by %s %s",
+			deployment.Name, owner.Kind, owner.Name))
+	}
+	if selector := deployment.Spec.Selector; selector == nil || len(selector.MatchExpressions) > 0 {
+		return nil, adoptionError(fmt.Sprintf("Deployment %s must select its pods with matchLabels only to be adopted",
+			deployment.Name))
+	}
+
+	return deployment, nil
+}
+
+// adoptedService returns the existing Service a component adopts, or nil if
+// it does not adopt one. Components adopting by selector adopt the Service
+// matching it, if there is one.
+func (r *VirtoolAppReconciler) adoptedService(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+) (*corev1.Service, error) {
+	adopt := component.Adopt
+	if adopt == nil || (adopt.ServiceName == "" && adopt.Selector == nil) {
+		return nil, nil
+	}
+
+	service := &corev1.Service{}
+	if adopt.ServiceName != "" {
+		found, err := r.getAdoptable(ctx, types.NamespacedName{Name: adopt.ServiceName, Namespace: app.Namespace}, service)
+		if err != nil {
+			return nil, fmt.Errorf("getting service %q: %w", adopt.ServiceName, err)
+		}
+		if !found {
+			return nil, adoptionError(fmt.Sprintf("Service %s adopted by component %s does not exist",
+				adopt.ServiceName, component.Name))
+		}
+	} else {
+		var serviceList corev1.ServiceList
+		if err := r.listAdopted(ctx, app, component, &serviceList); err != nil {
+			return nil, fmt.Errorf("listing services: %w", err)
+		}
+		switch len(serviceList.Items) {
+		case 0:
+			return nil, nil
+		case 1:
+			service = &serviceList.Items[0]
+		default:
+			return nil, adoptionError(fmt.Sprintf("%d Services match the selector of component %s, expected at most 1",
+				len(serviceList.Items), component.Name))
+		}
+	}
+
+	if owner := metav1.GetControllerOf(service); owner != nil && owner.UID != app.UID {
+		return nil, adoptionError(fmt.Sprintf("Service %s is already controlled by %s %s",
+			service.Name, owner.Kind, owner.Name))
+	}
+
+	return service, nil
+}
+
+// componentPodLabels returns the labels selecting the pods of a component:
+// those the operator renders, or the selector of the Deployment it adopted,
+// which is kept when the operator takes over the pod template.
+func (r *VirtoolAppReconciler) componentPodLabels(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+) (map[string]string, error) {
+	deployment, err := r.adoptedDeployment(ctx, app, component)
+	if err != nil {
+		return nil, err
+	}
+	if deployment == nil {
+		return selectorLabels(app, component), nil
+	}
+
+	return deployment.Spec.Selector.MatchLabels, nil
+}
+
+// runningVersion infers the Virtool version an adopted Deployment runs from
+// the image tag of its main container, the first in its pod template. The
+// pods are inspected so that a Deployment part way through a rollout is not
+// adopted; the template is used if it has none.
+func (r *VirtoolAppReconciler) runningVersion(ctx context.Context, deployment *appsv1.Deployment) (string, error) {
+	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+		return "", adoptionError(fmt.Sprintf("Deployment %s has no containers", deployment.Name))
+	}
+	main := deployment.Spec.Template.Spec.Containers[0]
+
+	var podList corev1.PodList
+	if err := r.listAdoptable(ctx, deployment.Namespace, deployment.Spec.Selector, &podList); err != nil {
+		return "", fmt.Errorf("listing pods: %w", err)
+	}
+
+	images := sets.New[string]()
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if container.Name == main.Name {
+				images.Insert(container.Image)
+			}
+		}
+	}
+	if images.Len() == 0 {
+		images.Insert(main.Image)
+	}
+	if images.Len() > 1 {
+		return "", adoptionError(fmt.Sprintf("Pods of Deployment %s run more than one image", deployment.Name))
+	}
+
+	running := images.UnsortedList()[0]
+	ref, err := image.Parse(running)
+	if err == nil {
+		_, err = semver.NewVersion(ref.Tag)
+	}
+	if err != nil {
+		return "", adoptionError(fmt.Sprintf("Unable to tell the version of Deployment %s from its image %s",
+			deployment.Name, running))
+	}
+
+	return ref.Tag, nil
+}
+
+// getAdoptable reads an object that may be adopted into obj and reports
+// whether it exists. Objects the operator has not labelled yet are not in the
+// manager's cache, so they are read from the API server when the cache does
+// not have them.
+func (r *VirtoolAppReconciler) getAdoptable(ctx context.Context, key types.NamespacedName, obj client.Object) (bool, error) {
+	err := r.Get(ctx, key, obj)
+	if apierrors.IsNotFound(err) {
+		err = r.apiReader().Get(ctx, key, obj)
+	}
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// listAdopted lists the objects a component adopts by selector into list.
+// Objects the operator has taken over carry the component's labels and are
+// read from the manager's cache. The API server is only asked while none have
+// been taken over yet.
+func (r *VirtoolAppReconciler) listAdopted(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	list client.ObjectList,
+) error {
+	selector, err := metav1.LabelSelectorAsSelector(component.Adopt.Selector)
+	if err != nil {
+		return err
+	}
+	requirements, _ := labels.SelectorFromSet(componentLabels(app, component)).Requirements()
+
+	err = r.List(ctx, list, client.InNamespace(app.Namespace),
+		client.MatchingLabelsSelector{Selector: selector.Add(requirements...)})
+	if err != nil || meta.LenList(list) > 0 {
+		return err
+	}
+
+	return r.listAdoptable(ctx, app.Namespace, component.Adopt.Selector, list)
+}
+
+// listAdoptable lists the objects that may be adopted matching selector into
+// list. They are read from the API server, as the cache only holds those the
+// operator has labelled and would leave out the rest.
+func (r *VirtoolAppReconciler) listAdoptable(
+	ctx context.Context,
+	namespace string,
+	selector *metav1.LabelSelector,
+	list client.ObjectList,
+) error {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return err
+	}
+
+	return r.apiReader().List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: labelSelector})
+}
+
+// apiReader returns the reader used for objects outside the manager's cache.
+func (r *VirtoolAppReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// takeoverObject returns the object applied to take over an existing object
+// of the given kind. It only sets labels, annotations and a controller
+// reference to owner, so that the rest of the object, and the pods of an
+// adopted workload, are left as they are.
+func takeoverObject(
+	scheme *runtime.Scheme,
+	owner metav1.Object,
+	gvk schema.GroupVersionKind,
+	key types.NamespacedName,
+	labels, annotations map[string]string,
+) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(key.Name)
+	obj.SetNamespace(key.Namespace)
+	obj.SetLabels(labels)
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+	if err := controllerutil.SetControllerReference(owner, obj, scheme); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
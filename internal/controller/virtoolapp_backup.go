@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// maxHistory is the number of version changes kept in the status history.
+const maxHistory = 10
+
+// needsBackup reports whether the databases must be backed up before the app
+// changes version. Initial installations have nothing to back up.
+func needsBackup(app *virtoolv1beta1.VirtoolApp) bool {
+	return app.Spec.Strategy.Backup != nil && app.Status.CurrentVersion != ""
+}
+
+// startUpgradeRecord adds a record for the upgrade to Status.TargetVersion to
+// the status history, dropping the oldest records beyond maxHistory.
+func startUpgradeRecord(app *virtoolv1beta1.VirtoolApp) *virtoolv1beta1.UpgradeRecord {
+	status := &app.Status
+
+	status.History = append(status.History, virtoolv1beta1.UpgradeRecord{
+		FromVersion: status.CurrentVersion,
+		ToVersion:   status.TargetVersion,
+		StartTime:   metav1.Now(),
+	})
+	if len(status.History) > maxHistory {
+		status.History = status.History[len(status.History)-maxHistory:]
+	}
+
+	return &status.History[len(status.History)-1]
+}
+
+// currentUpgradeRecord returns the history record of the in-progress upgrade,
+// or nil if it has none.
+func currentUpgradeRecord(app *virtoolv1beta1.VirtoolApp) *virtoolv1beta1.UpgradeRecord {
+	history := app.Status.History
+	if len(history) == 0 {
+		return nil
+	}
+
+	record := &history[len(history)-1]
+	if record.ToVersion != app.Status.TargetVersion || record.CompletionTime != nil {
+		return nil
+	}
+
+	return record
+}
+
+// advanceAfterBackup backs up the databases and moves the upgrade on to the
+// pre-update jobs once the backup has succeeded. A failed backup blocks the
+// upgrade until its Job is removed or the target version changes.
+func (r *VirtoolAppReconciler) advanceAfterBackup(ctx context.Context, app *virtoolv1beta1.VirtoolApp) (bool, error) {
+	record := currentUpgradeRecord(app)
+	if record == nil {
+		record = startUpgradeRecord(app)
+	}
+
+	state, err := r.runBackup(ctx, app, record)
+	if err != nil {
+		return false, err
+	}
+
+	switch state {
+	case jobStateFailed:
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "BackupFailed",
+			fmt.Sprintf("Backup %s failed", record.BackupID))
+		return false, nil
+	case jobStateRunning:
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionTrue, "BackingUp",
+			fmt.Sprintf("Waiting for backup %s to complete", record.BackupID))
+		return false, nil
+	}
+
+	app.Status.UpgradeStage = virtoolv1beta1.UpgradeStagePreUpdate
+
+	return true, nil
+}
+
+// runBackup ensures the backup Job of an upgrade exists and reports its
+// state. The backup identifier is recorded on the upgrade's history record
+// and also names the Job.
+func (r *VirtoolAppReconciler) runBackup(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	record *virtoolv1beta1.UpgradeRecord,
+) (jobState, error) {
+	if record.BackupID == "" {
+		record.BackupID = backupID(app, record)
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: record.BackupID, Namespace: app.Namespace}, job)
+	if apierrors.IsNotFound(err) {
+		job, err = r.createBackupJob(ctx, app, record.BackupID)
+	}
+	if err != nil {
+		return jobStateRunning, err
+	}
+
+	if job == nil {
+		log.FromContext(ctx).V(logging.DebugLevel).Info("No databases to back up", "version", record.FromVersion)
+		record.BackupID = ""
+		return jobStateSucceeded, nil
+	}
+
+	switch {
+	case jobHasCondition(job, batchv1.JobFailed):
+		return jobStateFailed, nil
+	case jobHasCondition(job, batchv1.JobComplete):
+		return jobStateSucceeded, nil
+	}
+
+	return jobStateRunning, nil
+}
+
+// createBackupJob creates the Job taking a backup. No Job is created if the
+// app uses neither PostgreSQL nor MongoDB.
+func (r *VirtoolAppReconciler) createBackupJob(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	id string,
+) (*batchv1.Job, error) {
+	databases, err := backupDatabases(ctx, r.apiReader(), app)
+	if err != nil || len(databases) == 0 {
+		return nil, err
+	}
+
+	job, err := renderBackupJob(app, app.Spec.Strategy.Backup, id, databases)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(app, job, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.apply(ctx, app, job); err != nil {
+		return nil, fmt.Errorf("creating backup job %q: %w", id, err)
+	}
+
+	log.FromContext(ctx).Info("Launched backup job", "job", id, "version", app.Status.CurrentVersion)
+	r.event(app, corev1.EventTypeNormal, eventBackupStarted, "Backing up version %s as %s",
+		app.Status.CurrentVersion, id)
+	addSpanEvent(ctx, eventBackupStarted, attrJob.String(id))
+
+	return job, nil
+}
+
+// backupID returns the identifier of the backup taken for an upgrade. It is
+// derived from the upgrade's start time so that it stays the same across
+// reconciles.
+func backupID(app *virtoolv1beta1.VirtoolApp, record *virtoolv1beta1.UpgradeRecord) string {
+	return timestampedName(app.Name+"-backup", record.StartTime.Time)
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/factory"
+)
+
+// BenchmarkReconcileUnrelatedPods reconciles an installed app in a cluster
+// holding a growing number of pods that do not belong to it. Neither the time
+// taken nor the objects read per reconcile should grow with the cluster.
+func BenchmarkReconcileUnrelatedPods(b *testing.B) {
+	for _, unrelated := range []int{0, 1000, 10000} {
+		b.Run(fmt.Sprintf("pods=%d", unrelated), func(b *testing.B) {
+			benchmarkReconcile(b, unrelated)
+		})
+	}
+}
+
+func benchmarkReconcile(b *testing.B, unrelated int) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+	if err := virtoolv1beta1.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+
+	app := factory.NewVirtoolApp("bench", "virtool", factory.WithVersion("7.1.0"), factory.WithStandardTopology())
+
+	// The fake client serializes every object of a kind on each list, so
+	// pods are served from an informer indexer as the manager's cache would.
+	pods := podIndexer()
+	addPod(b, pods, "virtool", "bench-api", appLabels(app))
+	for i := 0; i < unrelated; i++ {
+		// Half of the pods share the app's namespace.
+		namespace := "virtool"
+		if i%2 == 0 {
+			namespace = fmt.Sprintf("other-%d", i%20)
+		}
+		addPod(b, pods, namespace, fmt.Sprintf("unrelated-%d", i), map[string]string{labelName: "unrelated"})
+	}
+
+	var read int
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(app).
+		WithStatusSubresource(app, &virtoolv1beta1.VirtoolComponent{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if podList, ok := list.(*corev1.PodList); ok {
+					if err := listPods(pods, podList, opts...); err != nil {
+						return err
+					}
+				} else if err := c.List(ctx, list, opts...); err != nil {
+					return err
+				}
+				read += meta.LenList(list)
+				return nil
+			},
+			Patch: fakeApply,
+		})
+	for _, obj := range ownedTypes() {
+		builder = builder.WithIndex(obj, ownerIndex, indexOwner)
+	}
+	c := builder.Build()
+
+	r := &VirtoolAppReconciler{
+		Client:             c,
+		Scheme:             scheme,
+		DependencyCheckers: stubCheckers(nil),
+		indexed:            true,
+	}
+	components := &VirtoolComponentReconciler{Client: c, Scheme: scheme}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}}
+
+	// Bring the app up before measuring so that each iteration is a
+	// steady-state reconcile.
+	for i := 0; i < 4; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+		settleWorkloads(ctx, b, c, components)
+	}
+
+	read = 0
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(read)/float64(b.N), "objects-read/op")
+}
+
+// fakeApply emulates server-side apply patches, which the fake client cannot
+// create objects with, by creating the object or replacing all but the status
+// of the existing one, as if the operator were its only field manager.
+func fakeApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+
+	current := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	existing, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return err
+	}
+	if status, ok := existing["status"]; ok {
+		desired["status"] = status
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desired, obj); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(current.GetResourceVersion())
+
+	return c.Update(ctx, obj)
+}
+
+// podIndexer returns an informer store indexing pods by namespace and by
+// the app index.
+func podIndexer() toolscache.Indexer {
+	return toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{
+		toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+		appIndex: func(obj interface{}) ([]string, error) {
+			pod := obj.(*corev1.Pod)
+			keys := indexApp(pod)
+			for i, key := range keys {
+				keys[i] = pod.Namespace + "/" + key
+			}
+			return keys, nil
+		},
+	})
+}
+
+func addPod(b *testing.B, pods toolscache.Indexer, namespace, name string, podLabels map[string]string) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: podLabels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25"}}},
+	}
+	if err := pods.Add(pod); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// listPods lists pods from the indexer, using the app index when the field
+// selector matches on it in the same way as the manager's cache reader.
+func listPods(pods toolscache.Indexer, list *corev1.PodList, opts ...client.ListOption) error {
+	var listOpts client.ListOptions
+	listOpts.ApplyOptions(opts)
+
+	var (
+		objs []interface{}
+		err  error
+	)
+	if app, ok := fieldValue(listOpts.FieldSelector, appIndex); ok {
+		objs, err = pods.ByIndex(appIndex, listOpts.Namespace+"/"+app)
+	} else if listOpts.Namespace != "" {
+		objs, err = pods.ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+	} else {
+		objs = pods.List()
+	}
+	if err != nil {
+		return err
+	}
+
+	list.Items = nil
+	for _, obj := range objs {
+		pod := obj.(*corev1.Pod)
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		list.Items = append(list.Items, *pod.DeepCopy())
+	}
+	return nil
+}
+
+func fieldValue(selector fields.Selector, field string) (string, bool) {
+	if selector == nil {
+		return "", false
+	}
+	return selector.RequiresExactMatch(field)
+}
+
+// settleWorkloads marks the Jobs of the app complete, renders the Deployments
+// of its VirtoolComponents and marks them ready, as the Job, VirtoolComponent
+// and Deployment controllers would.
+func settleWorkloads(ctx context.Context, b *testing.B, c client.Client, components *VirtoolComponentReconciler) {
+	var jobs batchv1.JobList
+	if err := c.List(ctx, &jobs); err != nil {
+		b.Fatal(err)
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		if err := c.Status().Update(ctx, job); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reconcileAll := func() {
+		var componentList virtoolv1beta1.VirtoolComponentList
+		if err := c.List(ctx, &componentList); err != nil {
+			b.Fatal(err)
+		}
+		for i := range componentList.Items {
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&componentList.Items[i])}
+			if _, err := components.Reconcile(ctx, req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	reconcileAll()
+
+	var deployments appsv1.DeploymentList
+	if err := c.List(ctx, &deployments); err != nil {
+		b.Fatal(err)
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		deployment.Status = appsv1.DeploymentStatus{
+			ObservedGeneration: deployment.Generation,
+			Replicas:           1,
+			UpdatedReplicas:    1,
+			ReadyReplicas:      1,
+		}
+		if err := c.Status().Update(ctx, deployment); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reconcileAll()
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// versionAnnotation records the Virtool version an object was rendered for.
+const versionAnnotation = "virtool.ca/version"
+
+// restoreAnnotation is set on a VirtoolApp while a backup is restored into it
+// and holds the name of the VirtoolRestore. The app's components are scaled
+// to zero until it is removed.
+const restoreAnnotation = "virtool.ca/restore"
+
+// replicasAnnotation records the replicas of a component in the VirtoolApp
+// when they were last copied to its VirtoolComponent. The replicas of a
+// VirtoolComponent are only overwritten when the app's value changes, so that
+// the component can be scaled on its own in between.
+const replicasAnnotation = "virtool.ca/app-replicas"
+
+// reconcileComponents creates or updates a VirtoolComponent for every
+// component of the app that runs as a long-running workload at the given
+// version, deletes those that are no longer desired, records their state in
+// the app's status and reports whether all of them are fully rolled out and
+// ready.
+func (r *VirtoolAppReconciler) reconcileComponents(ctx context.Context, app *virtoolv1beta1.VirtoolApp, version string) (bool, error) {
+	components := resolveComponents(app)
+	statuses := make([]virtoolv1beta1.ComponentStatus, 0, len(components))
+	reconciled := make([]*virtoolv1beta1.VirtoolComponent, 0, len(components))
+	desired := make(map[string]struct{}, len(components))
+	allReady := true
+
+	for i := range components {
+		component := &components[i]
+		if !runsAsDeployment(component) {
+			continue
+		}
+
+		child, err := r.reconcileComponent(ctx, app, component, version)
+		if err != nil {
+			return false, err
+		}
+		desired[child.Name] = struct{}{}
+		reconciled = append(reconciled, child)
+
+		status := componentStatus(child, version)
+		if status.Status != virtoolv1beta1.ComponentStatusReady {
+			allReady = false
+		}
+		statuses = append(statuses, status)
+	}
+
+	app.Status.ComponentsStatus = statuses
+	setComponentDrift(app, reconciled)
+
+	children, err := r.ownedComponents(ctx, app)
+	if err != nil {
+		return false, err
+	}
+	for i := range children {
+		child := &children[i]
+		if _, ok := desired[child.Name]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, child); client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("deleting component %q: %w", child.Name, err)
+		}
+	}
+
+	return allReady, nil
+}
+
+// suspendComponents suspends every VirtoolComponent of the app, scaling its
+// workload to zero pods, and records their state in the app's status.
+func (r *VirtoolAppReconciler) suspendComponents(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	children, err := r.ownedComponents(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]virtoolv1beta1.ComponentStatus, 0, len(children))
+	suspended := make([]*virtoolv1beta1.VirtoolComponent, 0, len(children))
+
+	for i := range children {
+		child := &children[i]
+
+		if !child.Spec.Suspended {
+			desired := &virtoolv1beta1.VirtoolComponent{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        child.Name,
+					Namespace:   child.Namespace,
+					Labels:      labelsFor(child.Spec.AppName, child.Spec.ComponentName),
+					Annotations: map[string]string{replicasAnnotation: child.Annotations[replicasAnnotation]},
+				},
+				Spec: *child.Spec.DeepCopy(),
+			}
+			desired.Spec.Suspended = true
+			if err := controllerutil.SetControllerReference(app, desired, r.Scheme); err != nil {
+				return err
+			}
+			if _, err := r.apply(ctx, app, desired); err != nil {
+				return fmt.Errorf("suspending component %q: %w", child.Name, err)
+			}
+			child = desired
+		}
+		suspended = append(suspended, child)
+
+		status := componentStatus(child, child.Spec.Version)
+		status.Status = virtoolv1beta1.ComponentStatusProgressing
+		statuses = append(statuses, status)
+	}
+
+	app.Status.ComponentsStatus = statuses
+	setComponentDrift(app, suspended)
+
+	return nil
+}
+
+// ownedComponents returns the VirtoolComponents controlled by the app.
+func (r *VirtoolAppReconciler) ownedComponents(ctx context.Context, app *virtoolv1beta1.VirtoolApp) ([]virtoolv1beta1.VirtoolComponent, error) {
+	var componentList virtoolv1beta1.VirtoolComponentList
+	if err := r.List(ctx, &componentList, r.ownedListOptions(app)...); err != nil {
+		return nil, fmt.Errorf("listing components: %w", err)
+	}
+
+	owned := componentList.Items[:0]
+	for i := range componentList.Items {
+		if metav1.IsControlledBy(&componentList.Items[i], app) {
+			owned = append(owned, componentList.Items[i])
+		}
+	}
+
+	return owned, nil
+}
+
+// deploymentsStopped reports whether every component Deployment of the app
+// has been scaled down and has no pods left.
+func deploymentsStopped(ctx context.Context, c client.Reader, app *virtoolv1beta1.VirtoolApp) (bool, error) {
+	var deploymentList appsv1.DeploymentList
+	if err := c.List(ctx, &deploymentList,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels(appLabels(app)),
+	); err != nil {
+		return false, fmt.Errorf("listing deployments: %w", err)
+	}
+
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 || deployment.Status.Replicas != 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// reconcileComponent applies the VirtoolComponent for a single component.
+// Replicas are only copied from the app when they differ from those last
+// copied, so that scaling the VirtoolComponent directly sticks until the app
+// is changed. Components adopting a Deployment run as that Deployment, and
+// record the version it ran when it was adopted.
+func (r *VirtoolAppReconciler) reconcileComponent(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	version string,
+) (*virtoolv1beta1.VirtoolComponent, error) {
+	name := componentObjectName(app, component)
+	appReplicas := strconv.Itoa(int(component.Replicas))
+	replicas := component.Replicas
+
+	current := &virtoolv1beta1.VirtoolComponent{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, current)
+	exists := err == nil
+	if exists {
+		if copied, ok := current.Annotations[replicasAnnotation]; ok && copied == appReplicas {
+			replicas = current.Spec.Replicas
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting component %q: %w", name, err)
+	}
+
+	var deploymentName, adoptedVersion string
+	adopted, err := r.adoptedDeployment(ctx, app, component)
+	if err != nil {
+		return nil, fmt.Errorf("adopting deployment of component %q: %w", name, err)
+	}
+	if adopted != nil {
+		deploymentName = adopted.Name
+		if exists {
+			adoptedVersion = current.Spec.AdoptedVersion
+		} else if _, rendered := adopted.Spec.Template.Annotations[versionAnnotation]; !rendered {
+			if adoptedVersion, err = r.runningVersion(ctx, adopted); err != nil {
+				return nil, fmt.Errorf("adopting deployment of component %q: %w", name, err)
+			}
+		}
+	}
+
+	image, err := r.pinImage(ctx, app, imageForVersion(component.Image, version))
+	if err != nil {
+		return nil, err
+	}
+
+	liveness, readiness, startup := componentProbes(component)
+	child := &virtoolv1beta1.VirtoolComponent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      componentLabels(app, component),
+			Annotations: map[string]string{replicasAnnotation: appReplicas},
+		},
+		Spec: virtoolv1beta1.VirtoolComponentSpec{
+			AppName:       app.Name,
+			ComponentName: component.Name,
+			Role:          component.Role,
+			Version:       version,
+			Image:         image,
+			Command:       component.Command,
+			Args:          component.Args,
+			Env:           component.Env,
+			Replicas:      replicas,
+			Port:          component.Port,
+			Probes: &virtoolv1beta1.ProbesSpec{
+				Liveness:  liveness,
+				Readiness: readiness,
+				Startup:   startup,
+			},
+			Resources:      *component.Resources.DeepCopy(),
+			DriftPolicy:    app.Spec.DriftPolicy,
+			DeploymentName: deploymentName,
+			AdoptedVersion: adoptedVersion,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(app, child, r.Scheme); err != nil {
+		return nil, err
+	}
+	if _, err := r.apply(ctx, app, child); err != nil {
+		return nil, fmt.Errorf("reconciling component %q: %w", name, err)
+	}
+
+	return child, nil
+}
+
+// findContainer returns the container with the given name in the pod spec,
+// appending an empty one if it does not exist yet.
+func findContainer(spec *corev1.PodSpec, name string) *corev1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == name {
+			return &spec.Containers[i]
+		}
+	}
+
+	spec.Containers = append(spec.Containers, corev1.Container{Name: name})
+
+	return &spec.Containers[len(spec.Containers)-1]
+}
+
+// imageForVersion tags image with version unless it already carries a tag or
+// a digest.
+func imageForVersion(image, version string) string {
+	if version == "" || strings.Contains(image, "@") {
+		return image
+	}
+
+	if strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		return image
+	}
+
+	return image + ":" + version
+}
+
+// componentStatus summarises the state of a component from its
+// VirtoolComponent. Components whose latest spec has not been reconciled yet,
+// or that do not run the given version yet, are still progressing.
+func componentStatus(child *virtoolv1beta1.VirtoolComponent, version string) virtoolv1beta1.ComponentStatus {
+	status := virtoolv1beta1.ComponentStatus{
+		Name:            child.Spec.ComponentName,
+		CurrentVersion:  child.Status.CurrentVersion,
+		Status:          virtoolv1beta1.ComponentStatusProgressing,
+		ReadyReplicas:   child.Status.ReadyReplicas,
+		UpdatedReplicas: child.Status.UpdatedReplicas,
+	}
+
+	if child.Status.ObservedGeneration == child.Generation && child.Status.CurrentVersion == version &&
+		meta.IsStatusConditionTrue(child.Status.Conditions, virtoolv1beta1.ConditionReady) {
+		status.Status = virtoolv1beta1.ComponentStatusReady
+	}
+
+	return status
+}
+
+// readyComponents summarises component statuses as the number of ready
+// components out of the total, as shown by kubectl get virtoolapps.
+func readyComponents(statuses []virtoolv1beta1.ComponentStatus) string {
+	ready := 0
+	for _, status := range statuses {
+		if status.Status == virtoolv1beta1.ComponentStatusReady {
+			ready++
+		}
+	}
+
+	return fmt.Sprintf("%d/%d", ready, len(statuses))
+}
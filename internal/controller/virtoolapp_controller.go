@@ -18,20 +18,68 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
-	virtoolv1alpha1 "github.com/bryce-davidson/virtool-operator/api/v1alpha1"
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/compatibility"
+	"github.com/bryce-davidson/virtool-operator/internal/dependency"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // VirtoolAppReconciler reconciles a VirtoolApp object
 type VirtoolAppReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
-	Log    logr.Logger
+
+	// DependencyCheckers checks the datastores used by each app. The
+	// protocol-level checkers of the dependency package are used when nil.
+	DependencyCheckers dependency.Checkers
+
+	// Compatibility provides the manifest version changes are planned with.
+	// The manifest embedded in the operator is used when nil.
+	Compatibility *compatibility.Loader
+
+	// Recorder records events on VirtoolApps. No events are recorded when nil.
+	Recorder record.EventRecorder
+
+	// TracerProvider traces reconciles and upgrades. The global tracer
+	// provider is used when nil.
+	TracerProvider trace.TracerProvider
+
+	// Digests resolves the image tags of apps that pin image digests. A
+	// RegistryResolver is used when nil.
+	Digests DigestResolver
+
+	// VersionSkewThreshold is how long the pods of a component may run more
+	// than one version before the app reports a VersionSkew.
+	// defaultVersionSkewThreshold is used when zero.
+	VersionSkewThreshold time.Duration
+
+	// APIReader reads the existing objects adopted by apps, which are not in
+	// the manager's cache until the operator has labelled them, and the
+	// Secrets users create for apps. The client is used when nil.
+	APIReader client.Reader
+
+	// indexed is set once the field indexes the reconciler lists owned
+	// objects with have been registered with the manager's cache.
+	indexed bool
 }
 
 //+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolapps,verbs=get;list;watch;create;update;patch;delete
@@ -39,42 +87,168 @@ type VirtoolAppReconciler struct {
 //+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolapps/finalizers,verbs=update
 
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
-//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch,namespace=default
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the VirtoolApp object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It creates a VirtoolComponent for each component of the VirtoolApp, drives
+// upgrades between versions through their stages and records the observed
+// state of the components in the VirtoolApp status.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
-func (r *VirtoolAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	r.Log.Info("Starting reconciliation", "request", req)
+//
+// Logs are written to the logger of ctx with the app, namespace and any
+// in-progress upgrade attached.
+func (r *VirtoolAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx, logger := logging.WithValues(ctx, logging.KeyApp, req.Name, logging.KeyNamespace, req.Namespace)
+	logger.V(logging.DebugLevel).Info("Starting reconciliation")
+
+	var app virtoolv1beta1.VirtoolApp
+	if err := r.Get(ctx, req.NamespacedName, &app); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.DeleteApp(req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := app.Status.DeepCopy()
 
-	var podList corev1.PodList
-	if err := r.List(ctx, &podList, &client.ListOptions{}); err != nil {
-		r.Log.Error(err, "Unable to list pods")
+	if app.Status.UpgradeID != "" {
+		ctx, logger = logging.WithValues(ctx, logging.KeyUpgradeID, app.Status.UpgradeID)
+	}
+
+	ctx, span := r.startReconcileSpan(ctx, &app)
+	defer func() { endSpan(span, err) }()
+
+	if err := r.reconcileDependencies(ctx, &app); err != nil {
+		logger.Error(err, "Unable to reconcile dependencies")
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+
+	releaseCheckAfter, err := r.reconcileReleases(ctx, &app)
+	if err != nil {
+		logger.Error(err, "Unable to reconcile release channel")
 		return ctrl.Result{}, err
 	}
+	result.RequeueAfter = releaseCheckAfter
 
-	r.Log.Info("Listed pods", "count", len(podList.Items))
+	dependenciesReady, err := r.checkDependencies(ctx, &app)
+	if err != nil {
+		logger.Error(err, "Unable to check dependencies")
+		return ctrl.Result{}, err
+	}
 
-	for _, pod := range podList.Items {
-		r.Log.Info("Processing pod", "pod", pod.Name, "namespace", pod.Namespace)
-		for _, container := range pod.Spec.Containers {
-			r.Log.Info("Container image tag", "pod", pod.Name, "container", container.Name, "image", container.Image)
+	// Components are not rolled out and migrations are not run until every
+	// datastore is reachable. While a backup is being restored into the app,
+	// its components are kept scaled down instead.
+	if restore, ok := app.Annotations[restoreAnnotation]; ok {
+		logger.V(logging.DebugLevel).Info("Suspending components for restore", "restore", restore)
+		if err := r.suspendComponents(ctx, &app); err != nil {
+			logger.Error(err, "Unable to suspend components")
+			return ctrl.Result{}, err
+		}
+		setCondition(&app, virtoolv1beta1.ConditionReady, metav1.ConditionFalse, "Restoring",
+			fmt.Sprintf("Components are scaled down while restore %s runs", restore))
+	} else if dependenciesReady {
+		adopted, err := r.reconcileAdoption(ctx, &app)
+		if err != nil {
+			logger.Error(err, "Unable to adopt existing objects")
+			return ctrl.Result{}, err
+		}
+		if adopted {
+			if err := r.reconcileUpgrade(ctx, &app); err != nil {
+				logger.Error(err, "Unable to reconcile upgrade")
+				return ctrl.Result{}, err
+			}
+		}
+	} else {
+		logger.V(logging.DebugLevel).Info("Waiting for dependencies", "message",
+			meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionDependenciesReady).Message)
+		if result.RequeueAfter == 0 || dependencyRetryInterval < result.RequeueAfter {
+			result.RequeueAfter = dependencyRetryInterval
 		}
 	}
 
-	r.Log.Info("Reconciliation completed")
-	return ctrl.Result{}, nil
+	// Pinned tags are checked again for having been pushed even while
+	// nothing else happens to the app.
+	if app.Spec.PinImageDigests && (result.RequeueAfter == 0 || digestVerifyInterval < result.RequeueAfter) {
+		result.RequeueAfter = digestVerifyInterval
+	}
+
+	if err := r.reconcileServices(ctx, &app); err != nil {
+		logger.Error(err, "Unable to reconcile services")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDisruptionBudgets(ctx, &app); err != nil {
+		logger.Error(err, "Unable to reconcile disruption budgets")
+		return ctrl.Result{}, err
+	}
+
+	pods, err := r.componentPods(ctx, &app)
+	if err != nil {
+		logger.Error(err, "Unable to list pods")
+		return ctrl.Result{}, err
+	}
+
+	for component, componentPods := range pods {
+		logger.V(logging.DebugLevel).Info("Listed pods", "component", component, "count", len(componentPods))
+	}
+
+	if app.Status.ComponentsStatus == nil {
+		app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+	}
+
+	observed := observePodVersions(ctx, pods)
+	if skewAfter := r.setObservedVersions(&app, original.ComponentsStatus, observed); skewAfter > 0 &&
+		(result.RequeueAfter == 0 || skewAfter < result.RequeueAfter) {
+		result.RequeueAfter = skewAfter
+	}
+	app.Status.ObservedGeneration = app.Generation
+	app.Status.ReadyComponents = readyComponents(app.Status.ComponentsStatus)
+
+	r.recordTransitions(&app, original)
+	recordAppMetrics(&app, original)
+
+	if !equality.Semantic.DeepEqual(original, &app.Status) {
+		if err := r.Status().Update(ctx, &app); err != nil {
+			logger.Error(err, "Unable to update status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	logger.V(logging.DebugLevel).Info("Reconciliation completed")
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *VirtoolAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	r.indexed = true
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&virtoolv1alpha1.VirtoolApp{}).
+		For(&virtoolv1beta1.VirtoolApp{}).
+		// Reconcile adds the app and namespace to the logger itself, so they
+		// are not repeated under the keys controller-runtime uses by default.
+		WithLogConstructor(func(*reconcile.Request) logr.Logger {
+			return mgr.GetLogger().WithValues("controller", "virtoolapp")
+		}).
+		Owns(&virtoolv1beta1.VirtoolComponent{}).
+		Owns(&batchv1.Job{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.Secret{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
 		Complete(r)
 }
@@ -19,64 +19,42 @@ package controller
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
+	"net/http"
+	"net/http/httptest"
 	"time"
 
-	virtoolv1alpha1 "github.com/bryce-davidson/virtool-operator/api/v1alpha1"
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
 	"github.com/bryce-davidson/virtool-operator/factory"
-	"github.com/go-logr/logr"
+	"github.com/bryce-davidson/virtool-operator/internal/compatibility"
+	"github.com/bryce-davidson/virtool-operator/internal/dependency"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // ------------------------------------------------------------------------------
 
-type TestLogSink struct {
-	logChan chan string
-	buffer  []string
-	mu      sync.Mutex
-}
-
-func NewTestLogSink() *TestLogSink {
-	return &TestLogSink{
-		logChan: make(chan string, 100),
-	}
-}
-
-func (t *TestLogSink) Init(info logr.RuntimeInfo) {}
-func (t *TestLogSink) Enabled(level int) bool     { return true }
-func (t *TestLogSink) Info(level int, msg string, keysAndValues ...interface{}) {
-	logMsg := fmt.Sprintf("%s: %v", msg, keysAndValues)
-	t.mu.Lock()
-	t.buffer = append(t.buffer, logMsg)
-	t.mu.Unlock()
-	t.logChan <- logMsg
-}
-
-func (t *TestLogSink) Error(err error, msg string, keysAndValues ...interface{}) {
-	logMsg := fmt.Sprintf("ERROR: %s: %v", msg, err)
-	t.mu.Lock()
-	t.buffer = append(t.buffer, logMsg)
-	t.mu.Unlock()
-	t.logChan <- logMsg
-}
-
-func (t *TestLogSink) WithValues(keysAndValues ...interface{}) logr.LogSink { return t }
-func (t *TestLogSink) WithName(name string) logr.LogSink                    { return t }
-func (t *TestLogSink) String() string {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	return strings.Join(t.buffer, "\n")
-}
-
-// ------------------------------------------------------------------------------
-
 var _ = Describe("VirtoolApp Controller", func() {
 	const resourceName = "test-resource"
 	const namespace = "default"
@@ -92,6 +70,7 @@ var _ = Describe("VirtoolApp Controller", func() {
 
 	AfterEach(func() {
 		cleanupResource(ctx, typeNamespacedName)
+		cleanupOwnedObjects(ctx, namespace, resourceName)
 	})
 
 	Describe("Basic Reconciliation", func() {
@@ -106,60 +85,1290 @@ var _ = Describe("VirtoolApp Controller", func() {
 			Expect(result).To(Equal(ctrl.Result{}))
 
 			// Verify the reconciled state
-			var updatedApp virtoolv1alpha1.VirtoolApp
+			var updatedApp virtoolv1beta1.VirtoolApp
 			Expect(k8sClient.Get(ctx, typeNamespacedName, &updatedApp)).To(Succeed())
 		})
 	})
 
-	Describe("Container Image Logging", func() {
+	Describe("Disruption Budgets", func() {
+		pdbName := types.NamespacedName{Name: resourceName + "-default", Namespace: namespace}
+
+		setBudget := func(budget *virtoolv1beta1.DisruptionBudgetSpec) {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].DisruptionBudget = budget
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+		}
+
+		It("should create a budget for components that define one", func() {
+			minAvailable := intstr.FromInt(1)
+			setBudget(&virtoolv1beta1.DisruptionBudgetSpec{MinAvailable: &minAvailable})
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var pdb policyv1.PodDisruptionBudget
+			Expect(k8sClient.Get(ctx, pdbName, &pdb)).To(Succeed())
+			Expect(pdb.Spec.MinAvailable).To(Equal(&minAvailable))
+			Expect(pdb.Spec.Selector.MatchLabels).To(HaveKeyWithValue(labelComponent, "default"))
+			Expect(pdb.OwnerReferences).To(HaveLen(1))
+		})
+
+		It("should relax the budget while upgrading", func() {
+			minAvailable := intstr.FromInt(1)
+			setBudget(&virtoolv1beta1.DisruptionBudgetSpec{MinAvailable: &minAvailable})
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Status.CurrentVersion = "0.9.0"
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var pdb policyv1.PodDisruptionBudget
+			Expect(k8sClient.Get(ctx, pdbName, &pdb)).To(Succeed())
+			Expect(pdb.Spec.MinAvailable).To(BeNil())
+			Expect(pdb.Spec.MaxUnavailable.String()).To(Equal("100%"))
+		})
+
+		It("should keep the budget while an upgrade is refused", func() {
+			minAvailable := intstr.FromInt(1)
+			setBudget(&virtoolv1beta1.DisruptionBudgetSpec{MinAvailable: &minAvailable})
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Status.CurrentVersion = "2.0.0"
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			upgrading := meta.FindStatusCondition(reconciled.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+			Expect(upgrading.Reason).To(Equal("UnsupportedUpgrade"))
+
+			var pdb policyv1.PodDisruptionBudget
+			Expect(k8sClient.Get(ctx, pdbName, &pdb)).To(Succeed())
+			Expect(pdb.Spec.MinAvailable).To(Equal(&minAvailable))
+			Expect(pdb.Spec.MaxUnavailable).To(BeNil())
+		})
+
+		It("should delete the budget when it is removed from the spec", func() {
+			maxUnavailable := intstr.FromString("50%")
+			setBudget(&virtoolv1beta1.DisruptionBudgetSpec{MaxUnavailable: &maxUnavailable})
+			reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(k8sClient.Get(ctx, pdbName, &policyv1.PodDisruptionBudget{})).To(Succeed())
+
+			setBudget(nil)
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, pdbName, &policyv1.PodDisruptionBudget{})
+				return errors.IsNotFound(err)
+			}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
+		})
+	})
+
+	Describe("Component Deployments", func() {
+		deploymentName := types.NamespacedName{Name: resourceName + "-default", Namespace: namespace}
+
+		It("should render default probes for the API", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Role = virtoolv1beta1.ComponentRoleAPI
+			app.Spec.Components[0].Port = 9950
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/api"))
+			Expect(container.StartupProbe).NotTo(BeNil())
+			Expect(container.Ports).To(HaveLen(1))
+		})
+
+		It("should not render default probes for components without a role", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Port = 9950
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.LivenessProbe).To(BeNil())
+			Expect(container.ReadinessProbe).To(BeNil())
+			Expect(container.StartupProbe).To(BeNil())
+		})
+
+		It("should not render disabled probes", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Role = virtoolv1beta1.ComponentRoleAPI
+			app.Spec.Components[0].Port = 9950
+			app.Spec.Components[0].Probes = &virtoolv1beta1.ProbesSpec{
+				Disabled: []virtoolv1beta1.ProbeType{virtoolv1beta1.ProbeStartup},
+			}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/api"))
+			Expect(container.StartupProbe).To(BeNil())
+		})
+
+		It("should render overridden probes", func() {
+			readiness := &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					Exec: &corev1.ExecAction{Command: []string{"true"}},
+				},
+			}
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Probes = &virtoolv1beta1.ProbesSpec{Readiness: readiness}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].ReadinessProbe.Exec.Command).To(Equal([]string{"true"}))
+		})
+
+		It("should preserve fields set by other field managers", func() {
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+
+			patch := client.MergeFrom(deployment.DeepCopy())
+			metav1.SetMetaDataAnnotation(&deployment.ObjectMeta, "sidecar.example.com/injected", "true")
+			metav1.SetMetaDataAnnotation(&deployment.Spec.Template.ObjectMeta, "sidecar.example.com/status", "injected")
+			Expect(k8sClient.Patch(ctx, &deployment, patch, client.FieldOwner("sidecar-injector"))).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Annotations).To(HaveKeyWithValue("sidecar.example.com/injected", "true"))
+			Expect(deployment.Annotations).To(HaveKey(versionAnnotation))
+			Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue("sidecar.example.com/status", "injected"))
+			Expect(deployment.ManagedFields).To(ContainElement(SatisfyAll(
+				HaveField("Manager", fieldManager),
+				HaveField("Operation", metav1.ManagedFieldsOperationApply),
+			)))
+		})
+
+		It("should take back fields changed by other field managers and record the conflict", func() {
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			image := deployment.Spec.Template.Spec.Containers[0].Image
+
+			patch := client.MergeFrom(deployment.DeepCopy())
+			deployment.Spec.Template.Spec.Containers[0].Image = "example.com/virtool:edited"
+			Expect(k8sClient.Patch(ctx, &deployment, patch, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &VirtoolComponentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning ApplyConflict")))
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal(image))
+
+			var component virtoolv1beta1.VirtoolComponent
+			Expect(k8sClient.Get(ctx, deploymentName, &component)).To(Succeed())
+			Expect(component.Status.Drift).To(HaveLen(1))
+			Expect(component.Status.Drift[0].Managers).To(Equal([]string{"kubectl-edit"}))
+			Expect(component.Status.Drift[0].CorrectionTime).NotTo(BeNil())
+
+			app := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(app.Status.Drift).To(ConsistOf(HaveField("Name", deploymentName.Name)))
+		})
+
+		It("should only report drift when the drift policy is ReportOnly", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.DriftPolicy = virtoolv1beta1.DriftPolicyReportOnly
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			image := deployment.Spec.Template.Spec.Containers[0].Image
+
+			patch := client.MergeFrom(deployment.DeepCopy())
+			deployment.Spec.Template.Spec.Containers[0].Image = "example.com/virtool:hotfix"
+			Expect(k8sClient.Patch(ctx, &deployment, patch, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &VirtoolComponentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			for i := 0; i < 2; i++ {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deploymentName})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			// The drift is only news the first time it is detected.
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning DriftDetected")))
+			Expect(recorder.Events).NotTo(Receive())
+
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/virtool:hotfix"))
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.Drift).To(HaveLen(1))
+			Expect(reconciled.Status.Drift[0].Fields).To(ConsistOf(ContainSubstring("image")))
+			Expect(reconciled.Status.Drift[0].CorrectionTime).To(BeNil())
+			upgrading := meta.FindStatusCondition(reconciled.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+			Expect(upgrading.Reason).To(Equal("DriftBlocking"))
+
+			By("still applying the fields that did not drift")
+			reconciled.Spec.Components[0].Replicas = 2
+			Expect(k8sClient.Update(ctx, reconciled)).To(Succeed())
+
+			reconciled = reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/virtool:hotfix"))
+
+			reconciled.Spec.DriftPolicy = virtoolv1beta1.DriftPolicyCorrect
+			Expect(k8sClient.Update(ctx, reconciled)).To(Succeed())
+
+			reconciled = reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.Drift).To(HaveLen(1))
+			Expect(reconciled.Status.Drift[0].CorrectionTime).NotTo(BeNil())
+
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal(image))
+		})
+
+		It("should only report drift that a rollout would not overwrite", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.DriftPolicy = virtoolv1beta1.DriftPolicyReportOnly
+			minAvailable := intstr.FromInt(1)
+			app.Spec.Components[0].DisruptionBudget = &virtoolv1beta1.DisruptionBudgetSpec{MinAvailable: &minAvailable}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+
+			var pdb policyv1.PodDisruptionBudget
+			Expect(k8sClient.Get(ctx, deploymentName, &pdb)).To(Succeed())
+			patch := client.MergeFrom(pdb.DeepCopy())
+			maxUnavailable := intstr.FromString("50%")
+			pdb.Spec.MaxUnavailable = &maxUnavailable
+			Expect(k8sClient.Patch(ctx, &pdb, patch, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.Drift).To(ConsistOf(And(
+				HaveField("Kind", "PodDisruptionBudget"),
+				HaveField("CorrectionTime", BeNil()),
+			)))
+			upgrading := meta.FindStatusCondition(reconciled.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+			Expect(upgrading.Reason).To(Equal("RollingOut"))
+		})
+
+		It("should report pods of a component running more than one version for too long", func() {
+			for i, version := range []string{"1.0.0", "1.0.1"} {
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("skewed-pod-%d", i),
+						Namespace: namespace,
+						Labels:    labelsFor(resourceName, "default"),
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "default", Image: "example.com/virtool:" + version}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+				DeferCleanup(cleanupTestPod, ctx, namespace, pod.Name)
+			}
+
+			recorder := record.NewFakeRecorder(100)
+			reconciler := &VirtoolAppReconciler{
+				Client:               k8sClient,
+				Scheme:               k8sClient.Scheme(),
+				Recorder:             recorder,
+				VersionSkewThreshold: time.Hour,
+			}
+
+			By("recording the versions while the skew is within the threshold")
+			app := reconcileApp(ctx, reconciler, typeNamespacedName)
+			Expect(app.Status.ComponentsStatus).To(HaveLen(1))
+			Expect(app.Status.ComponentsStatus[0].ObservedVersions).To(Equal([]string{"1.0.0", "1.0.1"}))
+			Expect(app.Status.ComponentsStatus[0].VersionSkewTime).NotTo(BeNil())
+			Expect(meta.IsStatusConditionFalse(app.Status.Conditions, virtoolv1beta1.ConditionVersionSkew)).To(BeTrue())
+
+			By("reporting the skew once it lasts longer than the threshold")
+			reconciler.VersionSkewThreshold = time.Nanosecond
+			app = reconcileApp(ctx, reconciler, typeNamespacedName)
+			condition := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionVersionSkew)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("default runs 1.0.0, 1.0.1"))
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			Expect(events).To(ContainElement(HavePrefix("Warning VersionSkew")))
+		})
+
+		It("should pin images to the digest their tag pointed to and report tags pushed again", func() {
+			const pinned = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+			const pushed = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+
+			digest := pinned
+			registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v2/virtool/virtool/manifests/1.0.0" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Docker-Content-Digest", digest)
+			}))
+			defer registry.Close()
+			repository := registry.Listener.Addr().String() + "/virtool/virtool"
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.PinImageDigests = true
+			app.Spec.Components[0].Image = repository
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.ImageDigests).To(HaveLen(1))
+			Expect(reconciled.Status.ImageDigests[0].Image).To(Equal(repository + ":1.0.0"))
+			Expect(reconciled.Status.ImageDigests[0].Digest).To(Equal(pinned))
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal(repository + "@" + pinned))
+
+			By("reporting the tag once it points to another digest")
+			digest = pushed
+			reconciled.Status.ImageDigests[0].VerifyTime = metav1.NewTime(time.Now().Add(-2 * digestVerifyInterval))
+			Expect(k8sClient.Status().Update(ctx, reconciled)).To(Succeed())
+
+			recorder := record.NewFakeRecorder(100)
+			reconciler := &VirtoolAppReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			reconcileComponents(ctx, namespace, resourceName)
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			Expect(events).To(ContainElement(HavePrefix("Warning DigestChanged")))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			Expect(app.Status.ImageDigests[0].Digest).To(Equal(pinned))
+			Expect(app.Status.ImageDigests[0].CurrentDigest).To(Equal(pushed))
+
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal(repository + "@" + pinned))
+		})
+
+		It("should pin the images of update jobs and keep the digests of images in use", func() {
+			const pinned = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+			registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v2/virtool/virtool/manifests/1.0.0" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Docker-Content-Digest", pinned)
+			}))
+			defer registry.Close()
+			repository := registry.Listener.Addr().String() + "/virtool/virtool"
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.PinImageDigests = true
+			app.Spec.Components[0].Image = repository
+			app.Spec.Components[0].PreUpdateJob = &virtoolv1beta1.JobSpec{Image: repository, Command: []string{"migrate"}}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			// The running version is recorded first, followed by enough unused
+			// images to reach the limit.
+			now := metav1.Now()
+			app.Status.CurrentVersion = "0.9.0"
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			app.Status.ImageDigests = []virtoolv1beta1.ImageDigest{{
+				Image: repository + ":0.9.0", Digest: pinned, ResolveTime: now, VerifyTime: now,
+			}}
+			for i := 1; i < maxImageDigests; i++ {
+				app.Status.ImageDigests = append(app.Status.ImageDigests, virtoolv1beta1.ImageDigest{
+					Image: fmt.Sprintf("example.com/unused:%d", i), Digest: pinned, ResolveTime: now, VerifyTime: now,
+				})
+			}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.UpgradeStage).To(Equal(virtoolv1beta1.UpgradeStagePreUpdate))
+
+			var job batchv1.Job
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-default-pre-update-1-0-0",
+				Namespace: namespace,
+			}, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal(repository + "@" + pinned))
+
+			By("dropping the oldest image that is not in use")
+			images := make([]string, 0, len(reconciled.Status.ImageDigests))
+			for _, digest := range reconciled.Status.ImageDigests {
+				images = append(images, digest.Image)
+			}
+			Expect(images).To(HaveLen(maxImageDigests))
+			Expect(images).To(ContainElements(repository+":0.9.0", repository+":1.0.0"))
+			Expect(images).NotTo(ContainElement("example.com/unused:1"))
+		})
+
+		It("should only finish an upgrade once components are ready", func() {
+			app := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(app.Status.UpgradeStage).To(Equal(virtoolv1beta1.UpgradeStageRollout))
+			Expect(app.Status.CurrentVersion).To(BeEmpty())
+			Expect(app.Status.ReadyComponents).To(Equal("0/1"))
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+			deployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      1,
+			}
+			Expect(k8sClient.Status().Update(ctx, &deployment)).To(Succeed())
+
+			By("waiting for components that report another version")
+			reconcileComponents(ctx, namespace, resourceName)
+			var component virtoolv1beta1.VirtoolComponent
+			Expect(k8sClient.Get(ctx, deploymentName, &component)).To(Succeed())
+			component.Status.CurrentVersion = "0.9.0"
+			Expect(k8sClient.Status().Update(ctx, &component)).To(Succeed())
+
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, app)).To(Succeed())
+			Expect(app.Status.UpgradeStage).To(Equal(virtoolv1beta1.UpgradeStageRollout))
+
+			app = reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(app.Status.UpgradeStage).To(BeEmpty())
+			Expect(app.Status.CurrentVersion).To(Equal(app.Spec.Version))
+			Expect(app.Status.ReadyComponents).To(Equal("1/1"))
+		})
+	})
+
+	Describe("Adoption", func() {
+		adoptedName := types.NamespacedName{Name: "virtool-web", Namespace: namespace}
+		podLabels := map[string]string{"app": "virtool-web"}
+
 		BeforeEach(func() {
-			createTestPod(ctx, namespace)
+			replicas := int32(2)
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: adoptedName.Name, Namespace: namespace},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "web", Image: "example.com/virtool:1.0.0"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment, client.FieldOwner("kubectl-create"))).To(Succeed())
+
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: adoptedName.Name, Namespace: namespace},
+				Spec: corev1.ServiceSpec{
+					Selector: podLabels,
+					Ports:    []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(9950)}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, service, client.FieldOwner("kubectl-create"))).To(Succeed())
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Port = 9950
+			app.Spec.Components[0].Adopt = &virtoolv1beta1.AdoptSpec{
+				DeploymentName: adoptedName.Name,
+				ServiceName:    adoptedName.Name,
+			}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
 		})
 
 		AfterEach(func() {
-			cleanupTestPod(ctx, namespace)
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: adoptedName.Name, Namespace: namespace},
+			}))).To(Succeed())
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: adoptedName.Name, Namespace: namespace},
+			}))).To(Succeed())
 		})
 
-		It("should locate and print container images", func() {
-			logSink := NewTestLogSink()
-			logger := logr.New(logSink)
+		It("should take over existing objects without restarting their pods", func() {
+			var original appsv1.Deployment
+			Expect(k8sClient.Get(ctx, adoptedName, &original)).To(Succeed())
+
+			app := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(app.Status.CurrentVersion).To(Equal("1.0.0"))
+
+			By("adding owner references and labels to the Deployment")
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, adoptedName, &deployment)).To(Succeed())
+			Expect(metav1.GetControllerOf(&deployment).Kind).To(Equal("VirtoolComponent"))
+			Expect(deployment.Labels).To(HaveKeyWithValue(labelManagedBy, managerName))
+			Expect(deployment.Annotations).To(HaveKeyWithValue(versionAnnotation, "1.0.0"))
+
+			By("leaving its pod template as it is")
+			Expect(deployment.Generation).To(Equal(original.Generation))
+			Expect(deployment.Spec.Template).To(Equal(original.Spec.Template))
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+
+			By("adopting the Service without changing its selector")
+			var service corev1.Service
+			Expect(k8sClient.Get(ctx, adoptedName, &service)).To(Succeed())
+			Expect(metav1.IsControlledBy(&service, app)).To(BeTrue())
+			Expect(service.Spec.Selector).To(Equal(podLabels))
+
+			By("not creating objects of its own")
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-default", Namespace: namespace}, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-default", Namespace: namespace}, &corev1.Service{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should observe the versions the pods of an adopted Deployment run", func() {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "virtool-web-pod", Namespace: namespace, Labels: podLabels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web", Image: "example.com/virtool:1.0.0"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			DeferCleanup(cleanupTestPod, ctx, namespace, pod.Name)
+
+			reconcileApp(ctx, nil, typeNamespacedName)
+			app := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(app.Status.ComponentsStatus).To(ConsistOf(
+				HaveField("ObservedVersions", Equal([]string{"1.0.0"})),
+			))
+		})
 
+		It("should report a Deployment that does not exist", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Components[0].Adopt.DeploymentName = "missing"
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconciled := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(reconciled.Status.CurrentVersion).To(BeEmpty())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, virtoolv1beta1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("AdoptionFailed"))
+			Expect(condition.Message).To(ContainSubstring("missing"))
+		})
+	})
+
+	Describe("Schema Validation", func() {
+		const invalidResourceName = "invalid-resource"
+
+		AfterEach(func() {
+			cleanupResource(ctx, types.NamespacedName{Name: invalidResourceName, Namespace: namespace})
+		})
+
+		DescribeTable("should refuse invalid apps",
+			func(opts ...factory.VirtoolAppOption) {
+				app := factory.NewVirtoolApp(invalidResourceName, namespace, opts...)
+				err := k8sClient.Create(ctx, app)
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "got %v", err)
+			},
+			Entry("with a version that is not semver", factory.WithVersion("latest")),
+			Entry("with duplicate component names", factory.WithRoles(
+				virtoolv1beta1.ComponentRoleAPI, virtoolv1beta1.ComponentRoleAPI,
+			)),
+			Entry("with negative replicas", func(v *virtoolv1beta1.VirtoolApp) {
+				v.Spec.Components[0].Replicas = -1
+			}),
+			Entry("with a workflow runner without an image", factory.WithWorkflowRunner("nuvs", "")),
+			Entry("with a component named after a dependency", func(v *virtoolv1beta1.VirtoolApp) {
+				v.Spec.Components[0].Name = "redis"
+			}),
+			Entry("with a probe that is both set and disabled", func(v *virtoolv1beta1.VirtoolApp) {
+				v.Spec.Components[0].Probes = &virtoolv1beta1.ProbesSpec{
+					Liveness: &corev1.Probe{},
+					Disabled: []virtoolv1beta1.ProbeType{virtoolv1beta1.ProbeLiveness},
+				}
+			}),
+			Entry("with an adoption selecting nothing", func(v *virtoolv1beta1.VirtoolApp) {
+				v.Spec.Components[0].Adopt = &virtoolv1beta1.AdoptSpec{ServiceName: "virtool"}
+			}),
+			Entry("with both managed and external dependencies", func(v *virtoolv1beta1.VirtoolApp) {
+				v.Spec.Dependencies = &virtoolv1beta1.DependenciesSpec{
+					Redis: &virtoolv1beta1.DependencySpec{
+						Managed:  &virtoolv1beta1.ManagedDependencySpec{},
+						External: &virtoolv1beta1.ExternalDependencySpec{},
+					},
+				}
+			}),
+		)
+
+		It("should refuse changes to the storage class of a managed dependency", func() {
+			storageClass := "standard"
+			app := factory.NewVirtoolApp(invalidResourceName, namespace, factory.WithManagedDependencies())
+			app.Spec.Dependencies.Postgres.Managed.StorageClassName = &storageClass
+			Expect(k8sClient.Create(ctx, app)).To(Succeed())
+
+			storageClass = "fast"
+			app.Spec.Dependencies.Postgres.Managed.StorageClassName = &storageClass
+			err := k8sClient.Update(ctx, app)
+			Expect(errors.IsInvalid(err)).To(BeTrue(), "got %v", err)
+		})
+
+		It("should show versions and ready components as printer columns", func() {
+			var crd apiextensionsv1.CustomResourceDefinition
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "virtoolapps.virtool.virtool.ca"}, &crd)).To(Succeed())
+
+			for _, version := range crd.Spec.Versions {
+				var columns []string
+				for _, column := range version.AdditionalPrinterColumns {
+					columns = append(columns, column.Name)
+				}
+				Expect(columns).To(Equal([]string{"Desired", "Current", "Ready", "Age"}), version.Name)
+			}
+		})
+	})
+
+	Describe("Component Roles", func() {
+		const roleResourceName = "role-resource"
+		roleNamespacedName := types.NamespacedName{Name: roleResourceName, Namespace: namespace}
+
+		BeforeEach(func() {
+			app := factory.NewVirtoolApp(roleResourceName, namespace,
+				factory.WithVersion("7.1.0"),
+				factory.WithStandardTopology(),
+			)
+			Expect(k8sClient.Create(ctx, app)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			cleanupResource(ctx, roleNamespacedName)
+			cleanupOwnedObjects(ctx, namespace, roleResourceName)
+		})
+
+		It("should render components from their role templates", func() {
 			reconciler := &VirtoolAppReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Log:    logger,
 			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: roleNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			reconcileComponents(ctx, namespace, roleResourceName)
+
+			By("running migrations as a pre-update job")
+			var job batchv1.Job
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      roleResourceName + "-migration-pre-update-7-1-0",
+				Namespace: namespace,
+			}, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"migration", "apply"}))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal(factory.ServerImage + ":7.1.0"))
+
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: roleNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
 
-			go func() {
-				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: typeNamespacedName})
+			By("rendering the API with its command, port and environment")
+			var api appsv1.Deployment
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: roleResourceName + "-api", Namespace: namespace}, &api)).To(Succeed())
+			container := api.Spec.Template.Spec.Containers[0]
+			Expect(container.Args).To(Equal([]string{"server", "api"}))
+			Expect(container.Ports[0].ContainerPort).To(Equal(int32(9950)))
+			Expect(container.Env).To(ContainElement(HaveField("Name", "VT_POSTGRES_CONNECTION_STRING")))
+
+			By("pointing the UI at the API service")
+			var ui appsv1.Deployment
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: roleResourceName + "-ui", Namespace: namespace}, &ui)).To(Succeed())
+			Expect(ui.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "VT_API_URL",
+				Value: "http://" + roleResourceName + "-api:9950",
+			}))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: roleResourceName + "-api", Namespace: namespace}, &corev1.Service{})).To(Succeed())
+
+			By("not running migrations as a deployment")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: roleResourceName + "-migration", Namespace: namespace}, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: roleResourceName + "-migration", Namespace: namespace}, &virtoolv1beta1.VirtoolComponent{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep replicas set through the scale subresource until the app changes them", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			reconcileAll := func() {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: roleNamespacedName})
 				Expect(err).NotTo(HaveOccurred())
-			}()
+				reconcileComponents(ctx, namespace, roleResourceName)
+			}
+			reconcileAll()
+
+			apiName := types.NamespacedName{Name: roleResourceName + "-api", Namespace: namespace}
+			var component virtoolv1beta1.VirtoolComponent
+			Expect(k8sClient.Get(ctx, apiName, &component)).To(Succeed())
+			Expect(component.OwnerReferences).To(HaveLen(1))
+			Expect(component.Status.Selector).To(ContainSubstring(labelComponent + "=api"))
 
-			expectedLogs := []string{
-				"Starting reconciliation",
-				"Listed pods",
-				"Processing pod",
-				"Container image tag",
-				"test-pod",
-				"container1",
-				"nginx:1.14.2",
-				"container2",
-				"busybox:1.28",
-				"Reconciliation completed",
+			By("scaling the component on its own")
+			scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: 3}}
+			Expect(k8sClient.SubResource("scale").Update(ctx, &component, client.WithSubResourceBody(scale))).To(Succeed())
+			reconcileAll()
+
+			var deployment appsv1.Deployment
+			Expect(k8sClient.Get(ctx, apiName, &deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(3)))
+
+			By("applying replicas changed in the app")
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, roleNamespacedName, &app)).To(Succeed())
+			for i := range app.Spec.Components {
+				if app.Spec.Components[i].Name == "api" {
+					app.Spec.Components[i].Replicas = 2
+				}
 			}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+			reconcileAll()
 
-			Eventually(func() bool {
-				return containsAllLogs(logSink.buffer, expectedLogs)
-			}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, apiName, &deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+		})
+
+		It("should pull role images from the app's image repository", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, roleNamespacedName, &app)).To(Succeed())
+			app.Spec.Image.Repository = "registry.example.com/virtool"
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: roleNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var job batchv1.Job
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      roleResourceName + "-migration-pre-update-7-1-0",
+				Namespace: namespace,
+			}, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("registry.example.com/virtool/virtool:7.1.0"))
+		})
+	})
+
+	Describe("Managed Dependencies", func() {
+		const depResourceName = "dep-resource"
+		depNamespacedName := types.NamespacedName{Name: depResourceName, Namespace: namespace}
+
+		BeforeEach(func() {
+			app := factory.NewVirtoolApp(depResourceName, namespace,
+				factory.WithStandardTopology(),
+				factory.WithManagedDependencies(),
+			)
+			app.Spec.Dependencies.Redis = &virtoolv1beta1.DependencySpec{
+				External: &virtoolv1beta1.ExternalDependencySpec{
+					ConnectionSecret: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "external-redis"},
+						Key:                  "url",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, app)).To(Succeed())
+
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "external-redis", Namespace: namespace},
+				StringData: map[string]string{"url": "redis://external-redis:6379"},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			cleanupResource(ctx, depNamespacedName)
+			cleanupOwnedObjects(ctx, namespace, depResourceName)
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "external-redis", Namespace: namespace},
+			}))).To(Succeed())
+		})
+
+		It("should provision managed datastores and wire them into components", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client:             k8sClient,
+				Scheme:             k8sClient.Scheme(),
+				DependencyCheckers: stubCheckers(nil),
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: depNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("creating a StatefulSet and Service for each managed datastore")
+			for _, name := range []string{"postgres", "mongodb"} {
+				key := types.NamespacedName{Name: depResourceName + "-" + name, Namespace: namespace}
+				Expect(k8sClient.Get(ctx, key, &appsv1.StatefulSet{})).To(Succeed())
+				Expect(k8sClient.Get(ctx, key, &corev1.Service{})).To(Succeed())
+			}
+
+			By("not provisioning externally managed datastores")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: depResourceName + "-redis", Namespace: namespace}, &appsv1.StatefulSet{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("generating credentials and connection strings")
+			var credentials corev1.Secret
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      depResourceName + "-postgres-credentials",
+				Namespace: namespace,
+			}, &credentials)).To(Succeed())
+			Expect(credentials.Data["password"]).NotTo(BeEmpty())
+
+			var connections corev1.Secret
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      depResourceName + "-connections",
+				Namespace: namespace,
+			}, &connections)).To(Succeed())
+			Expect(string(connections.Data["postgres"])).To(ContainSubstring(string(credentials.Data["password"])))
+			Expect(connections.Data).NotTo(HaveKey("redis"))
+
+			By("labelling the secrets it creates for the manager's cache")
+			Expect(credentials.Labels).To(HaveKeyWithValue(labelManagedBy, managerName))
+			Expect(connections.Labels).To(HaveKeyWithValue(labelManagedBy, managerName))
+
+			By("reading the external datastore from its secret")
+			var job batchv1.Job
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      depResourceName + "-migration-pre-update-1-0-0",
+				Namespace: namespace,
+			}, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name: "VT_REDIS_CONNECTION_STRING",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "external-redis"},
+					Key:                  "url",
+				}},
+			}))
+		})
+
+		It("should wait for unreachable datastores before running migrations", func() {
+			checkers := stubCheckers(nil)
+			checkers["mongodb"] = dependency.CheckerFunc(func(context.Context, string) error {
+				return fmt.Errorf("connection refused")
+			})
+
+			reconciler := &VirtoolAppReconciler{
+				Client:             k8sClient,
+				Scheme:             k8sClient.Scheme(),
+				DependencyCheckers: checkers,
+			}
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: depNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("reporting which datastore failed")
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, depNamespacedName, &app)).To(Succeed())
+			condition := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionDependenciesReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("mongodb: connection refused"))
+			Expect(condition.Message).NotTo(ContainSubstring("postgres"))
+
+			By("not running migrations")
+			err = k8sClient.Get(ctx, types.NamespacedName{
+				Name:      depResourceName + "-migration-pre-update-1-0-0",
+				Namespace: namespace,
+			}, &batchv1.Job{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Upgrade Backups", func() {
+		const backupResourceName = "backup-resource"
+		backupNamespacedName := types.NamespacedName{Name: backupResourceName, Namespace: namespace}
+
+		BeforeEach(func() {
+			app := factory.NewVirtoolApp(backupResourceName, namespace, factory.WithBackupClaim("backups"))
+			Expect(k8sClient.Create(ctx, app)).To(Succeed())
+
+			app.Status.CurrentVersion = "0.9.0"
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, app)).To(Succeed())
+
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupResourceName + "-connections",
+					Namespace: namespace,
+					Labels:    map[string]string{labelInstance: backupResourceName},
+				},
+				StringData: map[string]string{"postgres": "postgresql://virtool@postgres:5432/virtool"},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			cleanupResource(ctx, backupNamespacedName)
+			cleanupOwnedObjects(ctx, namespace, backupResourceName)
+		})
+
+		It("should back up the databases before changing version", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client:             k8sClient,
+				Scheme:             k8sClient.Scheme(),
+				DependencyCheckers: stubCheckers(nil),
+			}
+			By("recording the backup in the status history")
+			app := reconcileApp(ctx, reconciler, backupNamespacedName)
+			Expect(app.Status.UpgradeStage).To(Equal(virtoolv1beta1.UpgradeStageBackup))
+			Expect(app.Status.History).To(HaveLen(1))
+			record := app.Status.History[0]
+			Expect(record.FromVersion).To(Equal("0.9.0"))
+			Expect(record.ToVersion).To(Equal(app.Spec.Version))
+			Expect(record.BackupID).NotTo(BeEmpty())
+
+			By("dumping the databases to the configured claim")
+			var job batchv1.Job
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: record.BackupID, Namespace: namespace}, &job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal("postgres"))
+			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+
+			By("blocking the upgrade when the backup fails")
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+			app = reconcileApp(ctx, reconciler, backupNamespacedName)
+			Expect(app.Status.UpgradeStage).To(Equal(virtoolv1beta1.UpgradeStageBackup))
+			condition := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+			Expect(condition.Reason).To(Equal("BackupFailed"))
+			Expect(condition.Message).To(ContainSubstring(record.BackupID))
+		})
+	})
+
+	Describe("Version Compatibility", func() {
+		manifestName := types.NamespacedName{Name: "compatibility", Namespace: namespace}
+		var recorder *record.FakeRecorder
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: manifestName.Name, Namespace: manifestName.Namespace},
+				Data: map[string]string{compatibility.ConfigMapKey: `
+releases:
+  - version: 1.0.0
+    upgradeFrom: 0.9.0
+`},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: manifestName.Name, Namespace: manifestName.Namespace},
+			})).To(Succeed())
+		})
+
+		reconcileFrom := func(currentVersion string) *virtoolv1beta1.VirtoolApp {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Status.CurrentVersion = currentVersion
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+			reconciler := &VirtoolAppReconciler{
+				Client:        k8sClient,
+				Scheme:        k8sClient.Scheme(),
+				Compatibility: &compatibility.Loader{Reader: k8sClient, ConfigMap: manifestName},
+				Recorder:      recorder,
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			return &app
+		}
+
+		It("should refuse to downgrade", func() {
+			app := reconcileFrom("2.0.0")
+			Expect(app.Status.TargetVersion).To(BeEmpty())
+
+			upgrading := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+			Expect(upgrading).NotTo(BeNil())
+			Expect(upgrading.Status).To(Equal(metav1.ConditionFalse))
+			Expect(upgrading.Reason).To(Equal("UnsupportedUpgrade"))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning UnsupportedUpgrade")))
+
+			By("keeping components at the current version")
+			var component virtoolv1beta1.VirtoolComponent
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-default", Namespace: namespace},
+				&component)).To(Succeed())
+			Expect(component.Spec.Version).To(Equal("2.0.0"))
+			Expect(meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionReady)).NotTo(BeNil())
+		})
+
+		It("should upgrade through the versions the manifest requires", func() {
+			app := reconcileFrom("0.8.0")
+			Expect(app.Status.TargetVersion).To(Equal("0.9.0"))
+			Expect(app.Status.UpgradePath).To(Equal([]string{"0.9.0", "1.0.0"}))
+			Expect(app.Status.CompletedHops).To(BeZero())
+			Expect(recorder.Events).To(Receive(Equal("Normal UpgradeStarted Upgrading from 0.8.0 to 0.9.0")))
+			Expect(app.Status.History[len(app.Status.History)-1].ToVersion).To(Equal("0.9.0"))
+		})
+
+		It("should resume a multi-hop upgrade from the hop it reached", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Status.TargetVersion = "1.0.0"
+			app.Status.UpgradeStage = virtoolv1beta1.UpgradeStagePreUpdate
+			app.Status.UpgradePath = []string{"0.9.0", "1.0.0"}
+			app.Status.CompletedHops = 1
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+			resumed := reconcileFrom("0.9.0")
+			Expect(resumed.Status.TargetVersion).To(Equal("1.0.0"))
+			Expect(resumed.Status.UpgradePath).To(Equal([]string{"0.9.0", "1.0.0"}))
+			Expect(resumed.Status.CompletedHops).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("Metrics", func() {
+		It("should export the versions and component state of the app", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+
+			Expect(testutil.ToFloat64(metrics.AppInfo.WithLabelValues(namespace, resourceName, "", app.Spec.Version))).
+				To(Equal(1.0))
+			Expect(testutil.ToFloat64(metrics.AppUpgrading.WithLabelValues(namespace, resourceName))).To(Equal(1.0))
+			for _, component := range app.Status.ComponentsStatus {
+				Expect(testutil.ToFloat64(metrics.ComponentReady.WithLabelValues(namespace, resourceName, component.Name))).
+					To(BeZero())
+			}
+		})
+
+		It("should remove the series of deleted apps", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			cleanupResource(ctx, typeNamespacedName)
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(metrics.AppInfo.DeletePartialMatch(metrics.AppLabels(namespace, resourceName))).To(BeZero())
+		})
+	})
+
+	Describe("Tracing", func() {
+		It("should trace each reconcile of an upgrade as part of one trace", func() {
+			exporter := tracetest.NewInMemoryExporter()
+			reconciler := &VirtoolAppReconciler{
+				Client:         k8sClient,
+				Scheme:         k8sClient.Scheme(),
+				TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+			}
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			Expect(app.Status.UpgradeTraceParent).NotTo(BeEmpty())
+
+			spanNamed := func(name string) tracetest.SpanStub {
+				for _, span := range exporter.GetSpans() {
+					if span.Name == name {
+						return span
+					}
+				}
+				Fail("no span named " + name)
+				return tracetest.SpanStub{}
+			}
+
+			upgradeTrace := spanNamed("Upgrade").SpanContext.TraceID()
+			Expect(app.Status.UpgradeTraceParent).To(ContainSubstring(upgradeTrace.String()))
+			Expect(spanNamed("UpgradePreUpdate").SpanContext.TraceID()).To(Equal(upgradeTrace))
+
+			exporter.Reset()
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spanNamed("Reconcile").SpanContext.TraceID()).To(Equal(upgradeTrace))
+		})
+	})
+
+	Describe("Release Channel", func() {
+		var releases *httptest.Server
+
+		BeforeEach(func() {
+			releases = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `[{"tag_name": "v1.1.0"}, {"tag_name": "v1.0.3"}, {"tag_name": "1.0.2"}]`)
+			}))
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Strategy.Channel = &virtoolv1beta1.ChannelSpec{VersionConstraint: "~1.0", URL: releases.URL}
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			app.Status.CurrentVersion = app.Spec.Version
+			app.Status.ComponentsStatus = []virtoolv1beta1.ComponentStatus{}
+			Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			releases.Close()
+		})
+
+		It("should report the newest release matching the constraint", func() {
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			app := reconcileApp(ctx, reconciler, typeNamespacedName)
+			Expect(app.Status.AvailableVersion).To(Equal("1.0.3"))
+			Expect(meta.IsStatusConditionTrue(app.Status.Conditions, virtoolv1beta1.ConditionUpdateAvailable)).To(BeTrue())
+			Expect(app.Spec.Version).To(Equal("1.0.0"))
+		})
+
+		It("should move to the newest release when updating automatically", func() {
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			app.Spec.Strategy.Channel.AutoUpdate = true
+			Expect(k8sClient.Update(ctx, &app)).To(Succeed())
+
+			updated := reconcileApp(ctx, nil, typeNamespacedName)
+			Expect(updated.Spec.Version).To(Equal("1.0.3"))
+			Expect(updated.Status.TargetVersion).To(Equal("1.0.3"))
+		})
+	})
+
+	Describe("Container Image Logging", func() {
+		BeforeEach(func() {
+			createTestPod(ctx, namespace, "test-pod", map[string]string{
+				labelName:      appName,
+				labelInstance:  resourceName,
+				labelManagedBy: managerName,
+			})
+			createTestPod(ctx, namespace, "unrelated-pod", map[string]string{labelName: "unrelated"})
+		})
+
+		AfterEach(func() {
+			cleanupTestPod(ctx, namespace, "test-pod")
+			cleanupTestPod(ctx, namespace, "unrelated-pod")
+		})
+
+		It("should log the images of the app's containers with structured values", func() {
+			recorder := logging.NewRecorder()
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := reconciler.Reconcile(log.IntoContext(ctx, recorder.Logger()),
+				ctrl.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			images := map[string]string{}
+			for _, entry := range recorder.Find("Container image tag") {
+				Expect(entry.Level).To(Equal(logging.DebugLevel))
+				Expect(entry.Values["pod"]).NotTo(Equal("unrelated-pod"))
+				if entry.Values["pod"] == "test-pod" {
+					images[entry.Values["container"].(string)] = entry.Values["image"].(string)
+				}
+			}
+			Expect(images).To(Equal(map[string]string{"container1": "nginx:1.14.2", "container2": "busybox:1.28"}))
+
+			for _, entry := range recorder.Entries() {
+				Expect(entry.Values).To(HaveKeyWithValue(logging.KeyApp, resourceName))
+				Expect(entry.Values).To(HaveKeyWithValue(logging.KeyNamespace, namespace))
+			}
+		})
+	})
+
+	Describe("Logging", func() {
+		It("should log significant upgrade actions with the upgrade ID", func() {
+			recorder := logging.NewRecorder()
+			reconciler := &VirtoolAppReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := reconciler.Reconcile(log.IntoContext(ctx, recorder.Logger()),
+				ctrl.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var app virtoolv1beta1.VirtoolApp
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &app)).To(Succeed())
+			Expect(app.Status.UpgradeID).NotTo(BeEmpty())
+
+			started := recorder.Find("Starting upgrade")
+			Expect(started).To(HaveLen(1))
+			Expect(started[0].Level).To(BeZero())
+			Expect(started[0].Values).To(HaveKeyWithValue(logging.KeyUpgradeID, app.Status.UpgradeID))
+			Expect(started[0].Values).To(HaveKeyWithValue("to", app.Spec.Version))
 		})
 	})
 })
 
-func createTestPod(ctx context.Context, namespace string) {
+// stubCheckers returns dependency checkers that report err for every
+// datastore instead of connecting to it.
+func stubCheckers(err error) dependency.Checkers {
+	check := dependency.CheckerFunc(func(context.Context, string) error { return err })
+	return dependency.Checkers{"postgres": check, "mongodb": check, "redis": check}
+}
+
+func createTestPod(ctx context.Context, namespace, name string, labels map[string]string) {
 	testPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-pod",
+			Name:      name,
 			Namespace: namespace,
+			Labels:    labels,
 		},
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{
@@ -171,47 +1380,88 @@ func createTestPod(ctx context.Context, namespace string) {
 	Expect(k8sClient.Create(ctx, testPod)).To(Succeed())
 
 	Eventually(func() error {
-		return k8sClient.Get(ctx, types.NamespacedName{Name: "test-pod", Namespace: namespace}, &corev1.Pod{})
+		return k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &corev1.Pod{})
 	}, 10*time.Second, 250*time.Millisecond).Should(Succeed())
 }
 
 func cleanupResource(ctx context.Context, namespacedName types.NamespacedName) {
-	resource := &virtoolv1alpha1.VirtoolApp{}
+	resource := &virtoolv1beta1.VirtoolApp{}
 	err := k8sClient.Get(ctx, namespacedName, resource)
 	if err == nil {
 		Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 	}
 }
 
-func cleanupTestPod(ctx context.Context, namespace string) {
-	testPod := &corev1.Pod{}
-	err := k8sClient.Get(ctx, types.NamespacedName{Name: "test-pod", Namespace: namespace}, testPod)
-	if err == nil {
-		Expect(k8sClient.Delete(ctx, testPod)).To(Succeed())
+// cleanupOwnedObjects deletes the objects created for a VirtoolApp. The test
+// environment does not run the garbage collector, so owner references alone
+// do not remove them.
+func cleanupOwnedObjects(ctx context.Context, namespace, instance string) {
+	selector := client.MatchingLabels{labelInstance: instance}
+	lists := []client.ObjectList{
+		&virtoolv1beta1.VirtoolComponentList{},
+		&appsv1.DeploymentList{},
+		&appsv1.StatefulSetList{},
+		&batchv1.JobList{},
+		&corev1.SecretList{},
+		&corev1.ServiceList{},
+		&policyv1.PodDisruptionBudgetList{},
+	}
+
+	for _, list := range lists {
+		Expect(k8sClient.List(ctx, list, client.InNamespace(namespace), selector)).To(Succeed())
+		Expect(meta.EachListItem(list, func(obj runtime.Object) error {
+			return client.IgnoreNotFound(k8sClient.Delete(ctx, obj.(client.Object),
+				client.PropagationPolicy(metav1.DeletePropagationBackground)))
+		})).To(Succeed())
+	}
+}
+
+// reconcileApp reconciles a VirtoolApp, its components and then the app
+// again, as the components' status updates would trigger, and returns the
+// reconciled app. A nil reconciler reconciles with the default configuration.
+func reconcileApp(ctx context.Context, reconciler *VirtoolAppReconciler,
+	name types.NamespacedName) *virtoolv1beta1.VirtoolApp {
+	if reconciler == nil {
+		reconciler = &VirtoolAppReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
 	}
+
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: name})
+	Expect(err).NotTo(HaveOccurred())
+	reconcileComponents(ctx, name.Namespace, name.Name)
+	_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: name})
+	Expect(err).NotTo(HaveOccurred())
+
+	var app virtoolv1beta1.VirtoolApp
+	Expect(k8sClient.Get(ctx, name, &app)).To(Succeed())
+	return &app
 }
 
-func containsAllLogs(receivedLogs []string, expectedLogs []string) bool {
-	if len(expectedLogs) == 0 {
-		return true
+// reconcileComponents reconciles the VirtoolComponents of a VirtoolApp, as the
+// VirtoolComponent controller would once the app has created them.
+func reconcileComponents(ctx context.Context, namespace, instance string) {
+	reconciler := &VirtoolComponentReconciler{
+		Client: k8sClient,
+		Scheme: k8sClient.Scheme(),
 	}
 
-	receivedMap := make(map[string]struct{}, len(receivedLogs))
-	for _, log := range receivedLogs {
-		receivedMap[log] = struct{}{}
+	var componentList virtoolv1beta1.VirtoolComponentList
+	Expect(k8sClient.List(ctx, &componentList, client.InNamespace(namespace),
+		client.MatchingLabels{labelInstance: instance})).To(Succeed())
+	for i := range componentList.Items {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&componentList.Items[i]),
+		})
+		Expect(err).NotTo(HaveOccurred())
 	}
+}
 
-	for _, expected := range expectedLogs {
-		found := false
-		for received := range receivedMap {
-			if strings.Contains(received, expected) {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+func cleanupTestPod(ctx context.Context, namespace, name string) {
+	testPod := &corev1.Pod{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, testPod)
+	if err == nil {
+		Expect(k8sClient.Delete(ctx, testPod)).To(Succeed())
 	}
-	return true
 }
@@ -0,0 +1,530 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/dependency"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// dependencyLabel marks objects that belong to a datastore rather than to a
+// Virtool component and records which datastore it is.
+const dependencyLabel = "virtool.ca/dependency"
+
+// Keys of a managed datastore's credentials secret.
+const (
+	credentialsKeyUsername = "username"
+	credentialsKeyPassword = "password"
+)
+
+// dependencyRetryInterval is how long to wait before checking unavailable
+// datastores again.
+const dependencyRetryInterval = 30 * time.Second
+
+// errConnectionNotFound is reported when a datastore is configured but its
+// connection string cannot be found.
+var errConnectionNotFound = errors.New("connection string not found")
+
+// databaseName is the database Virtool uses in PostgreSQL and MongoDB.
+const databaseName = "virtool"
+
+// dependencyKind describes how to provision one kind of datastore.
+type dependencyKind struct {
+	// name identifies the datastore and is also its key in the connection
+	// secret.
+	name         string
+	image        string
+	port         int32
+	storageSize  string
+	dataPath     string
+	command      []string
+	args         []string
+	env          func(credentials string) []corev1.EnvVar
+	connectionFn func(host string, port int32, username, password string) string
+}
+
+// dependencyKinds are the datastores the operator can manage. Their names are
+// reserved component names in the API since component and dependency objects
+// are both named after the app.
+var dependencyKinds = []dependencyKind{
+	{
+		name:        connectionKeyPostgres,
+		image:       "postgres:15",
+		port:        5432,
+		storageSize: "10Gi",
+		dataPath:    "/var/lib/postgresql/data",
+		env: func(credentials string) []corev1.EnvVar {
+			return []corev1.EnvVar{
+				secretEnv("POSTGRES_USER", credentials, credentialsKeyUsername),
+				secretEnv("POSTGRES_PASSWORD", credentials, credentialsKeyPassword),
+				{Name: "POSTGRES_DB", Value: databaseName},
+				{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"},
+			}
+		},
+		connectionFn: func(host string, port int32, username, password string) string {
+			return fmt.Sprintf("postgresql://%s:%s@%s:%d/%s", username, password, host, port, databaseName)
+		},
+	},
+	{
+		name:        connectionKeyMongoDB,
+		image:       "mongo:6",
+		port:        27017,
+		storageSize: "10Gi",
+		dataPath:    "/data/db",
+		env: func(credentials string) []corev1.EnvVar {
+			return []corev1.EnvVar{
+				secretEnv("MONGO_INITDB_ROOT_USERNAME", credentials, credentialsKeyUsername),
+				secretEnv("MONGO_INITDB_ROOT_PASSWORD", credentials, credentialsKeyPassword),
+			}
+		},
+		connectionFn: func(host string, port int32, username, password string) string {
+			return fmt.Sprintf("mongodb://%s:%s@%s:%d/%s?authSource=admin", username, password, host, port, databaseName)
+		},
+	},
+	{
+		name:        connectionKeyRedis,
+		image:       "redis:7",
+		port:        6379,
+		storageSize: "1Gi",
+		dataPath:    "/data",
+		command:     []string{"redis-server"},
+		args:        []string{"--requirepass", "$(REDIS_PASSWORD)", "--appendonly", "yes"},
+		env: func(credentials string) []corev1.EnvVar {
+			return []corev1.EnvVar{
+				secretEnv("REDIS_PASSWORD", credentials, credentialsKeyPassword),
+			}
+		},
+		connectionFn: func(host string, port int32, _, password string) string {
+			return fmt.Sprintf("redis://:%s@%s:%d", password, host, port)
+		},
+	},
+}
+
+// dependencyFor returns the configuration of the named datastore, or nil if
+// the app does not configure it.
+func dependencyFor(app *virtoolv1beta1.VirtoolApp, name string) *virtoolv1beta1.DependencySpec {
+	dependencies := app.Spec.Dependencies
+	if dependencies == nil {
+		return nil
+	}
+
+	switch name {
+	case connectionKeyPostgres:
+		return dependencies.Postgres
+	case connectionKeyMongoDB:
+		return dependencies.MongoDB
+	case connectionKeyRedis:
+		return dependencies.Redis
+	}
+
+	return nil
+}
+
+// connectionSecretKey returns the secret key components read the connection
+// string of the named datastore from. External datastores are read from the
+// secret they reference and all others from the app's connection secret.
+func connectionSecretKey(app *virtoolv1beta1.VirtoolApp, name string) *corev1.SecretKeySelector {
+	if dependency := dependencyFor(app, name); dependency != nil && dependency.External != nil {
+		return dependency.External.ConnectionSecret.DeepCopy()
+	}
+
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: connectionSecretName(app)},
+		Key:                  name,
+	}
+}
+
+// reconcileDependencies provisions the managed datastores of the app, writes
+// their connection strings to the app's connection secret and removes the
+// workloads of datastores that are no longer managed. Persistent volumes and
+// credentials are kept so that re-enabling a datastore does not lose data.
+func (r *VirtoolAppReconciler) reconcileDependencies(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	connections := make(map[string][]byte)
+
+	for i := range dependencyKinds {
+		kind := &dependencyKinds[i]
+
+		dependency := dependencyFor(app, kind.name)
+		if dependency == nil || dependency.Managed == nil {
+			continue
+		}
+
+		connection, err := r.reconcileManagedDependency(ctx, app, kind, dependency.Managed)
+		if err != nil {
+			return err
+		}
+		connections[kind.name] = []byte(connection)
+	}
+
+	if len(connections) > 0 {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      connectionSecretName(app),
+				Namespace: app.Namespace,
+				Labels:    appLabels(app),
+			},
+			Data: connections,
+		}
+		if err := controllerutil.SetControllerReference(app, secret, r.Scheme); err != nil {
+			return err
+		}
+
+		if _, err := r.apply(ctx, app, secret); err != nil {
+			return fmt.Errorf("reconciling connection secret: %w", err)
+		}
+	}
+
+	return r.cleanupDependencies(ctx, app, connections)
+}
+
+// checkDependencies checks that every datastore the app uses is reachable,
+// records the outcome in the DependenciesReady condition and reports whether
+// all of them are. Datastores are considered in use when configured in the
+// spec or present in the app's connection secret.
+func (r *VirtoolAppReconciler) checkDependencies(ctx context.Context, app *virtoolv1beta1.VirtoolApp) (bool, error) {
+	checkers := r.DependencyCheckers
+	if checkers == nil {
+		checkers = dependency.DefaultCheckers()
+	}
+
+	var failures []string
+
+	for i := range dependencyKinds {
+		kind := &dependencyKinds[i]
+
+		checker, ok := checkers[kind.name]
+		if !ok {
+			continue
+		}
+
+		connection, err := connectionString(ctx, r.apiReader(), app, kind.name)
+		if errors.Is(err, errConnectionNotFound) {
+			// Datastores that are not configured are only checked when the
+			// connection secret provides them.
+			if dependencyFor(app, kind.name) != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", kind.name, err))
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if err := checker.Check(ctx, connection); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", kind.name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		setCondition(app, virtoolv1beta1.ConditionDependenciesReady, metav1.ConditionFalse, "DependencyUnavailable",
+			strings.Join(failures, "; "))
+		return false, nil
+	}
+
+	setCondition(app, virtoolv1beta1.ConditionDependenciesReady, metav1.ConditionTrue, "DependenciesAvailable",
+		"All datastores are reachable")
+
+	return true, nil
+}
+
+// connectionString reads the connection string of the named datastore from
+// the secret components read it from. The secret may have been created by a
+// user, so c should read from the API server rather than the manager's cache.
+func connectionString(ctx context.Context, c client.Reader, app *virtoolv1beta1.VirtoolApp, name string) (string, error) {
+	selector := connectionSecretKey(app, name)
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: app.Namespace}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", errConnectionNotFound
+		}
+		return "", err
+	}
+
+	value, ok := secret.Data[selector.Key]
+	if !ok || len(value) == 0 {
+		return "", errConnectionNotFound
+	}
+
+	return string(value), nil
+}
+
+// reconcileManagedDependency provisions a single managed datastore and
+// returns its connection string.
+func (r *VirtoolAppReconciler) reconcileManagedDependency(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	kind *dependencyKind,
+	spec *virtoolv1beta1.ManagedDependencySpec,
+) (string, error) {
+	name := dependencyObjectName(app, kind.name)
+
+	username, password, err := r.ensureCredentials(ctx, app, kind)
+	if err != nil {
+		return "", err
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: app.Namespace,
+			Labels:    dependencyLabels(app, kind.name),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  dependencySelectorLabels(app, kind.name),
+			Ports: []corev1.ServicePort{{
+				Name:       kind.name,
+				Port:       kind.port,
+				TargetPort: intstr.FromInt(int(kind.port)),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(app, service, r.Scheme); err != nil {
+		return "", err
+	}
+	if _, err := r.apply(ctx, app, service); err != nil {
+		return "", fmt.Errorf("reconciling %s service: %w", kind.name, err)
+	}
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace},
+	}
+	mutateDependencyStatefulSet(app, kind, spec, statefulSet)
+
+	// The volume claim templates cannot be changed once created, so those of
+	// an existing StatefulSet are applied as they are.
+	current := &appsv1.StatefulSet{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, current)
+	if err == nil {
+		statefulSet.Spec.VolumeClaimTemplates = current.Spec.VolumeClaimTemplates
+	} else if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("getting %s statefulset: %w", kind.name, err)
+	}
+	if err := controllerutil.SetControllerReference(app, statefulSet, r.Scheme); err != nil {
+		return "", err
+	}
+	if _, err := r.apply(ctx, app, statefulSet); err != nil {
+		return "", fmt.Errorf("reconciling %s statefulset: %w", kind.name, err)
+	}
+
+	return kind.connectionFn(name, kind.port, username, password), nil
+}
+
+// ensureCredentials returns the credentials of a managed datastore,
+// generating them on first use. The credentials secret is deliberately not
+// owned by the app so that it outlives the app along with the datastore's
+// persistent volume.
+func (r *VirtoolAppReconciler) ensureCredentials(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	kind *dependencyKind,
+) (string, string, error) {
+	name := credentialsSecretName(app, kind.name)
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, secret)
+	if err == nil {
+		return string(secret.Data[credentialsKeyUsername]), string(secret.Data[credentialsKeyPassword]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", "", fmt.Errorf("getting %s credentials: %w", kind.name, err)
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: app.Namespace,
+			Labels:    dependencyLabels(app, kind.name),
+		},
+		Data: map[string][]byte{
+			credentialsKeyUsername: []byte(databaseName),
+			credentialsKeyPassword: []byte(password),
+		},
+	}
+	if _, err := r.apply(ctx, app, secret); err != nil {
+		return "", "", fmt.Errorf("creating %s credentials: %w", kind.name, err)
+	}
+
+	log.FromContext(ctx).Info("Generated datastore credentials", "dependency", kind.name, "secret", name)
+
+	return databaseName, password, nil
+}
+
+// mutateDependencyStatefulSet renders the desired state of a managed
+// datastore into its StatefulSet.
+func mutateDependencyStatefulSet(
+	app *virtoolv1beta1.VirtoolApp,
+	kind *dependencyKind,
+	spec *virtoolv1beta1.ManagedDependencySpec,
+	statefulSet *appsv1.StatefulSet,
+) {
+	statefulSet.Labels = dependencyLabels(app, kind.name)
+
+	replicas := int32(1)
+	statefulSet.Spec.Replicas = &replicas
+	statefulSet.Spec.ServiceName = statefulSet.Name
+
+	statefulSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: dependencySelectorLabels(app, kind.name)}
+	statefulSet.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{dependencyVolumeClaim(kind, spec)}
+
+	template := &statefulSet.Spec.Template
+	template.Labels = dependencyLabels(app, kind.name)
+
+	image := spec.Image
+	if image == "" {
+		image = kind.image
+	}
+
+	container := findContainer(&template.Spec, kind.name)
+	container.Image = image
+	container.Command = kind.command
+	container.Args = kind.args
+	container.Env = kind.env(credentialsSecretName(app, kind.name))
+	container.Resources = *spec.Resources.DeepCopy()
+	container.Ports = []corev1.ContainerPort{{
+		Name:          kind.name,
+		ContainerPort: kind.port,
+		Protocol:      corev1.ProtocolTCP,
+	}}
+	container.VolumeMounts = []corev1.VolumeMount{{Name: "data", MountPath: kind.dataPath}}
+	container.ReadinessProbe = tcpProbe(kind.port, 10)
+	container.LivenessProbe = tcpProbe(kind.port, 30)
+}
+
+func dependencyVolumeClaim(kind *dependencyKind, spec *virtoolv1beta1.ManagedDependencySpec) corev1.PersistentVolumeClaim {
+	size := resource.MustParse(kind.storageSize)
+	if spec.StorageSize != nil {
+		size = spec.StorageSize.DeepCopy()
+	}
+
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: spec.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+// cleanupDependencies deletes the StatefulSets and Services of datastores
+// that are no longer managed.
+func (r *VirtoolAppReconciler) cleanupDependencies(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	managed map[string][]byte,
+) error {
+	opts := append(r.ownedListOptions(app), client.HasLabels{dependencyLabel})
+	lists := []client.ObjectList{&appsv1.StatefulSetList{}, &corev1.ServiceList{}}
+
+	for _, list := range lists {
+		if err := r.List(ctx, list, opts...); err != nil {
+			return fmt.Errorf("listing datastore objects: %w", err)
+		}
+
+		objects, err := controlledObjects(list, app)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objects {
+			if _, ok := managed[obj.GetLabels()[dependencyLabel]]; ok {
+				continue
+			}
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("deleting datastore object %q: %w", obj.GetName(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dependencyLabels returns the labels of objects belonging to a datastore.
+func dependencyLabels(app *virtoolv1beta1.VirtoolApp, name string) map[string]string {
+	labels := appLabels(app)
+	labels[labelComponent] = name
+	labels[dependencyLabel] = name
+	return labels
+}
+
+// dependencySelectorLabels returns the labels used to select the pods of a
+// datastore.
+func dependencySelectorLabels(app *virtoolv1beta1.VirtoolApp, name string) map[string]string {
+	return map[string]string{
+		labelName:       appName,
+		labelInstance:   app.Name,
+		dependencyLabel: name,
+	}
+}
+
+func dependencyObjectName(app *virtoolv1beta1.VirtoolApp, name string) string {
+	return app.Name + "-" + name
+}
+
+func credentialsSecretName(app *virtoolv1beta1.VirtoolApp, name string) string {
+	return app.Name + "-" + name + "-credentials"
+}
+
+func secretEnv(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// generatePassword returns a random password that is safe to embed in a
+// connection string without escaping.
+func generatePassword() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating password: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/image"
+	"github.com/bryce-davidson/virtool-operator/internal/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// digestVerifyInterval is how often the tags of pinned images are checked
+// for having been pushed again.
+const digestVerifyInterval = time.Hour
+
+// maxImageDigests bounds the number of pinned images recorded in an app's
+// status. Images that are still in use are kept beyond it.
+const maxImageDigests = 20
+
+// DigestResolver resolves the tag of an image to the digest it points to.
+type DigestResolver interface {
+	Digest(ctx context.Context, ref image.Reference) (string, error)
+}
+
+// RegistryResolver resolves digests with the registry an image is pulled
+// from. Registries on localhost are reached over plain HTTP, as local
+// registries usually are.
+type RegistryResolver struct {
+	// Client is used for requests. A client bounded by release.DefaultTimeout
+	// is used when it is nil.
+	Client *http.Client
+}
+
+// Digest returns the digest the tag of ref points to.
+func (r *RegistryResolver) Digest(ctx context.Context, ref image.Reference) (string, error) {
+	registry := &release.Registry{URL: registryURL(ref.Registry), Repository: ref.Repository, Client: r.Client}
+	return registry.Digest(ctx, ref.Tag)
+}
+
+// registryURL returns the base URL of the distribution API of a registry.
+func registryURL(host string) string {
+	if host == image.DefaultRegistry {
+		return "https://registry-1.docker.io"
+	}
+
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if name == "localhost" || net.ParseIP(name).IsLoopback() {
+		return "http://" + host
+	}
+
+	return "https://" + host
+}
+
+// pinImage returns the image a component or update job runs for a tagged
+// image. Apps that pin image digests run the digest the tag pointed to when it
+// was first rolled out, which is recorded in the app's status. Recorded tags are checked
+// again every digestVerifyInterval and a warning event is recorded if one has
+// been pushed again since it was pinned.
+func (r *VirtoolAppReconciler) pinImage(ctx context.Context, app *virtoolv1beta1.VirtoolApp, tagged string) (string, error) {
+	if !app.Spec.PinImageDigests {
+		return tagged, nil
+	}
+
+	ref, err := image.Parse(tagged)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		return tagged, nil
+	}
+	if ref.Tag == "" {
+		ref.Tag = "latest"
+	}
+
+	logger := log.FromContext(ctx)
+	now := metav1.Now()
+
+	record := findImageDigest(app.Status.ImageDigests, tagged)
+	if record == nil {
+		digest, err := r.digestResolver().Digest(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("pinning image %s: %w", tagged, err)
+		}
+		logger.Info("Pinned image", "image", tagged, "digest", digest)
+
+		digests := append(app.Status.ImageDigests, virtoolv1beta1.ImageDigest{
+			Image:       tagged,
+			Digest:      digest,
+			ResolveTime: now,
+			VerifyTime:  now,
+		})
+		if len(digests) > maxImageDigests {
+			inUse := imagesInUse(app)
+			inUse.Insert(tagged)
+			digests = trimImageDigests(digests, inUse)
+		}
+		app.Status.ImageDigests = digests
+		record = &digests[len(digests)-1]
+	} else if now.Sub(record.VerifyTime.Time) >= digestVerifyInterval {
+		if digest, err := r.digestResolver().Digest(ctx, ref); err != nil {
+			logger.Error(err, "Unable to verify pinned image", "image", tagged)
+		} else {
+			record.VerifyTime = now
+			if digest != record.Digest && digest != record.CurrentDigest {
+				logger.Info("Pinned tag was pushed again", "image", tagged, "digest", record.Digest, "currentDigest", digest)
+				r.event(app, corev1.EventTypeWarning, eventDigestChanged,
+					"%s now points to %s instead of the pinned %s, which components keep running", tagged, digest,
+					record.Digest)
+			}
+			record.CurrentDigest = ""
+			if digest != record.Digest {
+				record.CurrentDigest = digest
+			}
+		}
+	}
+
+	ref.Tag = ""
+	ref.Digest = record.Digest
+
+	return ref.String(), nil
+}
+
+// imagesInUse returns the tagged images the components and update jobs of the
+// app run at its current and target versions.
+func imagesInUse(app *virtoolv1beta1.VirtoolApp) sets.Set[string] {
+	inUse := sets.New[string]()
+
+	components := resolveComponents(app)
+	for _, version := range []string{app.Status.CurrentVersion, app.Status.TargetVersion} {
+		if version == "" {
+			continue
+		}
+		for i := range components {
+			component := &components[i]
+			inUse.Insert(imageForVersion(component.Image, version))
+			for _, job := range []*virtoolv1beta1.JobSpec{component.PreUpdateJob, component.PostUpdateJob} {
+				if job != nil {
+					inUse.Insert(imageForVersion(job.Image, version))
+				}
+			}
+		}
+	}
+
+	return inUse
+}
+
+// trimImageDigests drops the oldest records of images that are not in use
+// until at most maxImageDigests remain. The records of images in use are kept,
+// so that they are not resolved and pinned to another digest.
+func trimImageDigests(digests []virtoolv1beta1.ImageDigest, inUse sets.Set[string]) []virtoolv1beta1.ImageDigest {
+	excess := len(digests) - maxImageDigests
+	trimmed := make([]virtoolv1beta1.ImageDigest, 0, len(digests))
+
+	for _, digest := range digests {
+		if excess > 0 && !inUse.Has(digest.Image) {
+			excess--
+			continue
+		}
+		trimmed = append(trimmed, digest)
+	}
+
+	return trimmed
+}
+
+// findImageDigest returns the record of a tagged image, or nil if it has not
+// been pinned.
+func findImageDigest(digests []virtoolv1beta1.ImageDigest, tagged string) *virtoolv1beta1.ImageDigest {
+	for i := range digests {
+		if digests[i].Image == tagged {
+			return &digests[i]
+		}
+	}
+	return nil
+}
+
+// digestResolver returns the resolver used to pin image digests.
+func (r *VirtoolAppReconciler) digestResolver() DigestResolver {
+	if r.Digests != nil {
+		return r.Digests
+	}
+	return &RegistryResolver{}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// jobPhase names the stage of an upgrade an update job belongs to.
+type jobPhase string
+
+const (
+	jobPhasePreUpdate  jobPhase = "pre-update"
+	jobPhasePostUpdate jobPhase = "post-update"
+)
+
+// jobPhaseLabel records which phase an update job was launched for.
+const jobPhaseLabel = "virtool.ca/job-phase"
+
+// jobState is the outcome of a set of update jobs.
+type jobState int
+
+const (
+	jobStateRunning jobState = iota
+	jobStateSucceeded
+	jobStateFailed
+)
+
+// runUpdateJobs ensures the update job of the given phase exists for every
+// component that defines one and reports their combined state. When a job
+// has failed, the name of the failing component is returned as well.
+func (r *VirtoolAppReconciler) runUpdateJobs(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	phase jobPhase,
+	version string,
+) (jobState, string, error) {
+	state := jobStateSucceeded
+	var succeeded []*batchv1.Job
+
+	components := resolveComponents(app)
+	for i := range components {
+		component := &components[i]
+
+		spec := component.PreUpdateJob
+		if phase == jobPhasePostUpdate {
+			spec = component.PostUpdateJob
+		}
+		if spec == nil {
+			continue
+		}
+
+		jobCtx, _ := logging.WithValues(ctx, logging.KeyComponent, component.Name, logging.KeyPhase, phase)
+		job, err := r.ensureUpdateJob(jobCtx, app, component, spec, phase, version)
+		if err != nil {
+			return jobStateRunning, "", err
+		}
+
+		switch {
+		case jobHasCondition(job, batchv1.JobFailed):
+			return jobStateFailed, component.Name, nil
+		case !jobHasCondition(job, batchv1.JobComplete):
+			state = jobStateRunning
+		default:
+			succeeded = append(succeeded, job)
+		}
+	}
+
+	// The upgrade leaves the phase once its jobs have succeeded, so their
+	// durations are only observed once.
+	if state == jobStateSucceeded {
+		for _, job := range succeeded {
+			observeJobDuration(app, job, phase)
+		}
+	}
+
+	return state, "", nil
+}
+
+// observeJobDuration records the run time of a finished update job.
+func observeJobDuration(app *virtoolv1beta1.VirtoolApp, job *batchv1.Job, phase jobPhase) {
+	if job.Status.StartTime == nil || job.Status.CompletionTime == nil {
+		return
+	}
+
+	metrics.UpdateJobDuration.WithLabelValues(app.Namespace, app.Name, job.Labels[labelComponent], string(phase)).
+		Observe(job.Status.CompletionTime.Sub(job.Status.StartTime.Time).Seconds())
+}
+
+// ensureUpdateJob returns the update job for a component, creating it if it
+// does not exist. Jobs are named after the version they run for, so each
+// upgrade runs them exactly once.
+func (r *VirtoolAppReconciler) ensureUpdateJob(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	spec *virtoolv1beta1.JobSpec,
+	phase jobPhase,
+	version string,
+) (*batchv1.Job, error) {
+	name := updateJobName(app, component, phase, version)
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, job)
+	if err == nil {
+		return job, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting %s job %q: %w", phase, name, err)
+	}
+
+	image, err := r.pinImage(ctx, app, imageForVersion(spec.Image, version))
+	if err != nil {
+		return nil, err
+	}
+
+	job = renderUpdateJob(app, component, spec, phase, version, image)
+	job.Name = name
+	if err := controllerutil.SetControllerReference(app, job, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.apply(ctx, app, job); err != nil {
+		return nil, fmt.Errorf("creating %s job %q: %w", phase, name, err)
+	}
+
+	log.FromContext(ctx).Info("Launched update job", "job", name, "version", version)
+	r.event(app, corev1.EventTypeNormal, eventJobLaunched, "Launched %s job %s for version %s", phase, name, version)
+	addSpanEvent(ctx, eventJobLaunched, attrJob.String(name), attrJobPhase.String(string(phase)))
+
+	return job, nil
+}
+
+// renderUpdateJob builds the Job that runs a component's update job spec for
+// a version from the given image.
+func renderUpdateJob(
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	spec *virtoolv1beta1.JobSpec,
+	phase jobPhase,
+	version, image string,
+) *batchv1.Job {
+	labels := componentLabels(app, component)
+	labels[jobPhaseLabel] = string(phase)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{versionAnnotation: version},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    string(phase),
+						Image:   image,
+						Command: spec.Command,
+						Args:    spec.Args,
+						Env:     spec.Env,
+					}},
+				},
+			},
+		},
+	}
+}
+
+// updateJobName returns the name of a component's update job for a version.
+func updateJobName(
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	phase jobPhase,
+	version string,
+) string {
+	version = strings.ToLower(strings.NewReplacer(".", "-", "+", "-", "_", "-").Replace(version))
+	return fmt.Sprintf("%s-%s-%s", componentObjectName(app, component), phase, version)
+}
+
+func jobHasCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
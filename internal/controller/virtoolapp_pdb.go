@@ -0,0 +1,136 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// relaxedMaxUnavailable is the budget applied to every component while the
+// operator is upgrading the app, so that it is free to replace all pods.
+var relaxedMaxUnavailable = intstr.FromString("100%")
+
+// reconcileDisruptionBudgets applies a PodDisruptionBudget for each
+// component that defines one and deletes budgets that are no longer desired.
+func (r *VirtoolAppReconciler) reconcileDisruptionBudgets(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	desired := make(map[string]struct{})
+
+	components := resolveComponents(app)
+	for i := range components {
+		component := &components[i]
+		if component.DisruptionBudget == nil || !runsAsDeployment(component) {
+			continue
+		}
+
+		// Components whose objects cannot be adopted are reported by
+		// reconcileAdoption and left alone until they can.
+		podLabels, err := r.componentPodLabels(ctx, app, component)
+		var adoptionErr adoptionError
+		if errors.As(err, &adoptionErr) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		name := componentObjectName(app, component)
+		desired[name] = struct{}{}
+
+		pdb := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: app.Namespace,
+				Labels:    componentLabels(app, component),
+			},
+			Spec: disruptionBudgetSpec(app, component, podLabels),
+		}
+		if err := controllerutil.SetControllerReference(app, pdb, r.Scheme); err != nil {
+			return err
+		}
+
+		if _, err := r.apply(ctx, app, pdb); err != nil {
+			return fmt.Errorf("reconciling disruption budget %q: %w", name, err)
+		}
+	}
+
+	var pdbList policyv1.PodDisruptionBudgetList
+	if err := r.List(ctx, &pdbList, r.ownedListOptions(app)...); err != nil {
+		return fmt.Errorf("listing disruption budgets: %w", err)
+	}
+
+	for i := range pdbList.Items {
+		pdb := &pdbList.Items[i]
+		if _, ok := desired[pdb.Name]; ok || !metav1.IsControlledBy(pdb, app) {
+			continue
+		}
+		if err := r.Delete(ctx, pdb); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("deleting disruption budget %q: %w", pdb.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// disruptionBudgetSpec renders the PodDisruptionBudget spec for a component
+// whose pods carry podLabels. The configured budget is replaced with a fully
+// relaxed one while an upgrade is in progress.
+func disruptionBudgetSpec(
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	podLabels map[string]string,
+) policyv1.PodDisruptionBudgetSpec {
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+	}
+
+	if isUpgrading(app) {
+		maxUnavailable := relaxedMaxUnavailable
+		spec.MaxUnavailable = &maxUnavailable
+		return spec
+	}
+
+	if budget := component.DisruptionBudget; budget.MinAvailable != nil {
+		minAvailable := *budget.MinAvailable
+		spec.MinAvailable = &minAvailable
+	} else if budget.MaxUnavailable != nil {
+		maxUnavailable := *budget.MaxUnavailable
+		spec.MaxUnavailable = &maxUnavailable
+	}
+
+	return spec
+}
+
+// isUpgrading reports whether an upgrade is running. Upgrades that were
+// refused or are blocked until someone intervenes keep the configured budgets,
+// as components are not being replaced.
+func isUpgrading(app *virtoolv1beta1.VirtoolApp) bool {
+	if app.Status.TargetVersion == "" {
+		return false
+	}
+
+	upgrading := meta.FindStatusCondition(app.Status.Conditions, virtoolv1beta1.ConditionUpgrading)
+	return upgrading == nil || !blockingReasons[upgrading.Reason]
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// apiProbePath is answered by the Virtool API once it has connected to
+	// its databases.
+	apiProbePath = "/api"
+
+	// uiProbePath is served by the Virtool web client.
+	uiProbePath = "/"
+
+	// startupFailureThreshold allows a component five minutes to start
+	// before its other probes take over.
+	startupFailureThreshold = 30
+)
+
+// runnerProbeCommand checks that the Virtool process of a runner is alive.
+var runnerProbeCommand = []string{"pgrep", "-f", "virtool"}
+
+// componentProbes returns the liveness, readiness and startup probes for a
+// component, using the component's overrides where set. Disabled probes are
+// nil.
+func componentProbes(component *virtoolv1beta1.ComponentSpec) (liveness, readiness, startup *corev1.Probe) {
+	liveness, readiness, startup = defaultProbes(component)
+
+	probes := component.Probes
+	if probes == nil {
+		return liveness, readiness, startup
+	}
+
+	if probes.Liveness != nil {
+		liveness = probes.Liveness.DeepCopy()
+	}
+	if probes.Readiness != nil {
+		readiness = probes.Readiness.DeepCopy()
+	}
+	if probes.Startup != nil {
+		startup = probes.Startup.DeepCopy()
+	}
+
+	for _, probe := range probes.Disabled {
+		switch probe {
+		case virtoolv1beta1.ProbeLiveness:
+			liveness = nil
+		case virtoolv1beta1.ProbeReadiness:
+			readiness = nil
+		case virtoolv1beta1.ProbeStartup:
+			startup = nil
+		}
+	}
+
+	return liveness, readiness, startup
+}
+
+// defaultProbes returns the probes used when a component does not override
+// them. The API is checked against its /api endpoint and gated by a startup
+// probe so it only becomes ready once connected to its databases, and runners
+// are checked with a command. Components without a role run an image the
+// operator knows nothing about, so they have no default probes.
+func defaultProbes(component *virtoolv1beta1.ComponentSpec) (liveness, readiness, startup *corev1.Probe) {
+	switch component.Role {
+	case virtoolv1beta1.ComponentRoleAPI:
+		liveness = httpProbe(apiProbePath, component.Port, 30)
+		readiness = httpProbe(apiProbePath, component.Port, 10)
+
+		startup = httpProbe(apiProbePath, component.Port, 10)
+		startup.FailureThreshold = startupFailureThreshold
+
+		return liveness, readiness, startup
+	case virtoolv1beta1.ComponentRoleUI:
+		return httpProbe(uiProbePath, component.Port, 30), httpProbe(uiProbePath, component.Port, 10), nil
+	case virtoolv1beta1.ComponentRoleJobsAPI:
+		return tcpProbe(component.Port, 30), tcpProbe(component.Port, 10), nil
+	case virtoolv1beta1.ComponentRoleTasks, virtoolv1beta1.ComponentRoleWorkflowRunner:
+		return commandProbe(runnerProbeCommand, 30), commandProbe(runnerProbeCommand, 10), nil
+	}
+
+	return nil, nil, nil
+}
+
+func httpProbe(path string, port int32, periodSeconds int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   path,
+				Port:   intstr.FromInt(int(port)),
+				Scheme: corev1.URISchemeHTTP,
+			},
+		},
+		PeriodSeconds:    periodSeconds,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+}
+
+func tcpProbe(port int32, periodSeconds int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(int(port))},
+		},
+		PeriodSeconds:    periodSeconds,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+}
+
+func commandProbe(command []string, periodSeconds int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: append([]string(nil), command...)},
+		},
+		PeriodSeconds:    periodSeconds,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultReleasePollInterval is how often release channels are looked up when
+// they do not set an interval.
+const defaultReleasePollInterval = time.Hour
+
+// Repositories Virtool releases are published to.
+const (
+	githubRepository   = "virtool/virtool"
+	registryRepository = "ghcr.io/virtool/virtool"
+)
+
+// reconcileReleases looks up the release channel of the app when it is due,
+// records the newest matching release in the status and, if the channel
+// updates automatically, moves Spec.Version to it. It returns how long to wait
+// before the channel is due again, or zero if the app has no channel.
+func (r *VirtoolAppReconciler) reconcileReleases(ctx context.Context, app *virtoolv1beta1.VirtoolApp) (time.Duration, error) {
+	channel := app.Spec.Strategy.Channel
+	status := &app.Status
+
+	if channel == nil {
+		status.AvailableVersion = ""
+		status.LastReleaseCheckTime = nil
+		meta.RemoveStatusCondition(&status.Conditions, virtoolv1beta1.ConditionUpdateAvailable)
+		return 0, nil
+	}
+
+	interval := defaultReleasePollInterval
+	if channel.PollInterval != nil && channel.PollInterval.Duration > 0 {
+		interval = channel.PollInterval.Duration
+	}
+
+	now := time.Now()
+	if last := status.LastReleaseCheckTime; last == nil || !now.Before(last.Add(interval)) {
+		checked := metav1.NewTime(now)
+		status.LastReleaseCheckTime = &checked
+
+		available, err := release.Latest(ctx, releaseSource(channel), channel.VersionConstraint)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Unable to look up releases", "constraint", channel.VersionConstraint)
+			setCondition(app, virtoolv1beta1.ConditionUpdateAvailable, metav1.ConditionUnknown, "ReleaseCheckFailed",
+				err.Error())
+			return interval, nil
+		}
+
+		status.AvailableVersion = available
+	}
+
+	if !release.Newer(status.AvailableVersion, app.Spec.Version) {
+		setCondition(app, virtoolv1beta1.ConditionUpdateAvailable, metav1.ConditionFalse, "UpToDate",
+			fmt.Sprintf("No release matching %s is newer than %s", channel.VersionConstraint, app.Spec.Version))
+		return time.Until(status.LastReleaseCheckTime.Add(interval)), nil
+	}
+
+	setCondition(app, virtoolv1beta1.ConditionUpdateAvailable, metav1.ConditionTrue, "NewerRelease",
+		fmt.Sprintf("Version %s is available", status.AvailableVersion))
+
+	// Automatic updates wait for the app to be installed and for any
+	// in-progress upgrade to finish.
+	if channel.AutoUpdate && status.CurrentVersion == app.Spec.Version {
+		if err := r.updateVersion(ctx, app, status.AvailableVersion); err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Until(status.LastReleaseCheckTime.Add(interval)), nil
+}
+
+// updateVersion sets the desired version of the app. Only the spec is
+// patched so that the status changes made by this reconcile are kept.
+func (r *VirtoolAppReconciler) updateVersion(ctx context.Context, app *virtoolv1beta1.VirtoolApp, version string) error {
+	updated := app.DeepCopy()
+	patch := client.MergeFrom(app.DeepCopy())
+	updated.Spec.Version = version
+
+	if err := r.Patch(ctx, updated, patch); err != nil {
+		return fmt.Errorf("updating version to %s: %w", version, err)
+	}
+
+	log.FromContext(ctx).Info("Updating to newer release", "from", app.Spec.Version, "to", version)
+
+	app.ResourceVersion = updated.ResourceVersion
+	app.Generation = updated.Generation
+	app.Spec.Version = version
+
+	return nil
+}
+
+// releaseSource returns the source a release channel is looked up in.
+func releaseSource(channel *virtoolv1beta1.ChannelSpec) release.Source {
+	if channel.Source == virtoolv1beta1.ReleaseSourceRegistry {
+		repository := channel.Repository
+		if repository == "" {
+			repository = registryRepository
+		}
+
+		host, path, _ := strings.Cut(repository, "/")
+
+		url := channel.URL
+		if url == "" {
+			url = "https://" + host
+		}
+
+		return &release.Registry{URL: url, Repository: path}
+	}
+
+	repository := channel.Repository
+	if repository == "" {
+		repository = githubRepository
+	}
+
+	return &release.GitHub{URL: channel.URL, Repository: repository}
+}
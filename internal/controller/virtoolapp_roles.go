@@ -0,0 +1,214 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	apiPort     = 9950
+	jobsAPIPort = 9990
+	uiPort      = 9900
+)
+
+// Keys of the connection secret that hold the connection strings of the
+// Virtool datastores.
+const (
+	connectionKeyPostgres = "postgres"
+	connectionKeyMongoDB  = "mongodb"
+	connectionKeyRedis    = "redis"
+)
+
+// rolePorts holds the HTTP port served by each role that serves HTTP.
+var rolePorts = map[virtoolv1beta1.ComponentRole]int32{
+	virtoolv1beta1.ComponentRoleAPI:     apiPort,
+	virtoolv1beta1.ComponentRoleJobsAPI: jobsAPIPort,
+	virtoolv1beta1.ComponentRoleUI:      uiPort,
+}
+
+// virtoolCommand is the entrypoint of the Virtool server image.
+var virtoolCommand = []string{"virtool"}
+
+// roleTemplate holds the defaults applied to a component with a given role.
+type roleTemplate struct {
+	command []string
+	args    []string
+	env     func(app *virtoolv1beta1.VirtoolApp) []corev1.EnvVar
+}
+
+var roleTemplates = map[virtoolv1beta1.ComponentRole]roleTemplate{
+	virtoolv1beta1.ComponentRoleAPI: {
+		command: virtoolCommand,
+		args:    []string{"server", "api"},
+		env:     datastoreEnv,
+	},
+	virtoolv1beta1.ComponentRoleJobsAPI: {
+		command: virtoolCommand,
+		args:    []string{"server", "jobs"},
+		env:     datastoreEnv,
+	},
+	virtoolv1beta1.ComponentRoleTasks: {
+		command: virtoolCommand,
+		args:    []string{"tasks", "runner"},
+		env:     datastoreEnv,
+	},
+	virtoolv1beta1.ComponentRoleMigration: {
+		command: virtoolCommand,
+		args:    []string{"migration", "apply"},
+		env:     datastoreEnv,
+	},
+	virtoolv1beta1.ComponentRoleUI: {
+		env: func(app *virtoolv1beta1.VirtoolApp) []corev1.EnvVar {
+			return urlEnv(app, "VT_API_URL", virtoolv1beta1.ComponentRoleAPI)
+		},
+	},
+	virtoolv1beta1.ComponentRoleWorkflowRunner: {
+		env: func(app *virtoolv1beta1.VirtoolApp) []corev1.EnvVar {
+			return append(
+				urlEnv(app, "VT_JOBS_API_CONNECTION_STRING", virtoolv1beta1.ComponentRoleJobsAPI),
+				connectionEnv(app, "VT_REDIS_CONNECTION_STRING", connectionKeyRedis),
+			)
+		},
+	},
+}
+
+// resolveComponents returns the components of the app with the templates of
+// their roles applied.
+func resolveComponents(app *virtoolv1beta1.VirtoolApp) []virtoolv1beta1.ComponentSpec {
+	components := make([]virtoolv1beta1.ComponentSpec, len(app.Spec.Components))
+	for i := range app.Spec.Components {
+		components[i] = resolveComponent(app, &app.Spec.Components[i])
+	}
+	return components
+}
+
+// resolveComponent fills in the fields of a component that are not set with
+// the defaults of its role, including the image for the role in the app's
+// image repository. Components without a role are returned as is.
+func resolveComponent(app *virtoolv1beta1.VirtoolApp, component *virtoolv1beta1.ComponentSpec) virtoolv1beta1.ComponentSpec {
+	resolved := *component.DeepCopy()
+	resolved.Image = app.Spec.ComponentImage(component)
+
+	template, ok := roleTemplates[component.Role]
+	if !ok {
+		return resolved
+	}
+
+	if len(resolved.Command) == 0 {
+		resolved.Command = append([]string(nil), template.command...)
+	}
+	if len(resolved.Args) == 0 {
+		resolved.Args = append([]string(nil), template.args...)
+	}
+	if resolved.Port == 0 {
+		resolved.Port = rolePorts[component.Role]
+	}
+	if template.env != nil {
+		resolved.Env = mergeEnv(template.env(app), resolved.Env)
+	}
+
+	if component.Role == virtoolv1beta1.ComponentRoleMigration && resolved.PreUpdateJob == nil {
+		resolved.PreUpdateJob = &virtoolv1beta1.JobSpec{
+			Image:   resolved.Image,
+			Command: resolved.Command,
+			Args:    resolved.Args,
+			Env:     resolved.Env,
+		}
+	}
+
+	return resolved
+}
+
+// runsAsDeployment reports whether a component is run as a long-running
+// workload. Migration components only run as update jobs.
+func runsAsDeployment(component *virtoolv1beta1.ComponentSpec) bool {
+	return component.Role != virtoolv1beta1.ComponentRoleMigration
+}
+
+// mergeEnv returns the template environment with the variables of overrides
+// replacing those with the same name and any other overrides appended.
+func mergeEnv(template, overrides []corev1.EnvVar) []corev1.EnvVar {
+	merged := make([]corev1.EnvVar, 0, len(template)+len(overrides))
+	index := make(map[string]int, len(template))
+
+	for _, env := range template {
+		index[env.Name] = len(merged)
+		merged = append(merged, env)
+	}
+
+	for _, env := range overrides {
+		if i, ok := index[env.Name]; ok {
+			merged[i] = env
+			continue
+		}
+		merged = append(merged, env)
+	}
+
+	return merged
+}
+
+// datastoreEnv wires the connection strings of every Virtool datastore into
+// a component.
+func datastoreEnv(app *virtoolv1beta1.VirtoolApp) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		connectionEnv(app, "VT_POSTGRES_CONNECTION_STRING", connectionKeyPostgres),
+		connectionEnv(app, "VT_MONGODB_CONNECTION_STRING", connectionKeyMongoDB),
+		connectionEnv(app, "VT_REDIS_CONNECTION_STRING", connectionKeyRedis),
+	}
+}
+
+// connectionEnv returns a variable holding the connection string of the
+// named datastore.
+func connectionEnv(app *virtoolv1beta1.VirtoolApp, name, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name:      name,
+		ValueFrom: &corev1.EnvVarSource{SecretKeyRef: connectionSecretKey(app, key)},
+	}
+}
+
+// urlEnv returns a variable holding the in-cluster URL of the first component
+// with the given role, or nothing if the app has no such component.
+func urlEnv(app *virtoolv1beta1.VirtoolApp, name string, role virtoolv1beta1.ComponentRole) []corev1.EnvVar {
+	for i := range app.Spec.Components {
+		component := &app.Spec.Components[i]
+		if component.Role != role {
+			continue
+		}
+
+		port := component.Port
+		if port == 0 {
+			port = rolePorts[role]
+		}
+
+		return []corev1.EnvVar{{
+			Name:  name,
+			Value: fmt.Sprintf("http://%s:%d", componentObjectName(app, component), port),
+		}}
+	}
+
+	return nil
+}
+
+// connectionSecretName returns the name of the secret holding the connection
+// strings of the app's datastores.
+func connectionSecretName(app *virtoolv1beta1.VirtoolApp) string {
+	return app.Name + "-connections"
+}
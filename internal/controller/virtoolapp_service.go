@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileServices applies a Service for each component that
+// serves HTTP and deletes Services that are no longer desired.
+func (r *VirtoolAppReconciler) reconcileServices(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	desired := make(map[string]struct{})
+
+	components := resolveComponents(app)
+	for i := range components {
+		component := &components[i]
+		if component.Port == 0 || !runsAsDeployment(component) {
+			continue
+		}
+
+		componentCtx, _ := logging.WithValues(ctx, logging.KeyComponent, component.Name)
+
+		// Components whose objects cannot be adopted are reported by
+		// reconcileAdoption and left alone until they can.
+		adopted, err := r.adoptedService(ctx, app, component)
+		var adoptionErr adoptionError
+		if errors.As(err, &adoptionErr) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if adopted != nil {
+			desired[adopted.Name] = struct{}{}
+			if err := r.takeOverService(ctx, app, component, adopted); err != nil {
+				return fmt.Errorf("adopting service %q: %w", adopted.Name, err)
+			}
+			continue
+		}
+
+		podLabels, err := r.componentPodLabels(ctx, app, component)
+		if errors.As(err, &adoptionErr) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		name := componentObjectName(app, component)
+		desired[name] = struct{}{}
+
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: app.Namespace,
+				Labels:    componentLabels(app, component),
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: podLabels,
+				Ports: []corev1.ServicePort{{
+					Name:       "http",
+					Port:       component.Port,
+					TargetPort: intstr.FromString("http"),
+					Protocol:   corev1.ProtocolTCP,
+				}},
+			},
+		}
+		if err := controllerutil.SetControllerReference(app, service, r.Scheme); err != nil {
+			return err
+		}
+
+		result, err := r.apply(ctx, app, service)
+		if err != nil {
+			return fmt.Errorf("reconciling service %q: %w", name, err)
+		}
+
+		r.recordDrift(componentCtx, app, result, "Service", name)
+	}
+
+	var serviceList corev1.ServiceList
+	if err := r.List(ctx, &serviceList, r.ownedListOptions(app)...); err != nil {
+		return fmt.Errorf("listing services: %w", err)
+	}
+
+	for i := range serviceList.Items {
+		service := &serviceList.Items[i]
+		if _, ok := desired[service.Name]; ok || !metav1.IsControlledBy(service, app) {
+			continue
+		}
+		if _, ok := service.Labels[dependencyLabel]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, service); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("deleting service %q: %w", service.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// takeOverService adds the operator's labels and a controller reference to a
+// Service adopted by a component. Its selector and ports are left to the
+// Service's other managers.
+func (r *VirtoolAppReconciler) takeOverService(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	component *virtoolv1beta1.ComponentSpec,
+	service *corev1.Service,
+) error {
+	obj, err := takeoverObject(r.Scheme, app, corev1.SchemeGroupVersion.WithKind("Service"),
+		client.ObjectKeyFromObject(service), componentLabels(app, component), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.apply(ctx, app, obj)
+	return err
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileUpgrade moves the app towards Spec.Version. An upgrade backs up the
+// databases if the app has a backup policy, runs the pre-update jobs, rolls
+// every component out at the new version and then runs the post-update jobs,
+// only advancing to the next stage once the previous one has finished and
+// every component passes its readiness probe.
+//
+// When the compatibility manifest requires intermediate versions, the upgrade
+// is made one hop at a time: the jobs and rollout run for each version of
+// Status.UpgradePath in turn. Progress is recorded in the status so that an
+// upgrade resumes from the hop it reached after an operator restart.
+func (r *VirtoolAppReconciler) reconcileUpgrade(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	status := &app.Status
+	logger := log.FromContext(ctx)
+
+	if status.CurrentVersion == app.Spec.Version {
+		if status.TargetVersion != "" {
+			logger.Info("Abandoning upgrade", "to", status.TargetVersion, "version", status.CurrentVersion)
+			metrics.RollbacksTotal.WithLabelValues(app.Namespace, app.Name).Inc()
+			r.event(app, corev1.EventTypeWarning, eventUpgradeRolledBack,
+				"Abandoned the upgrade to %s and returned components to %s", status.TargetVersion, status.CurrentVersion)
+		}
+		status.TargetVersion = ""
+		status.UpgradeStage = ""
+		status.UpgradePath = nil
+		status.CompletedHops = 0
+		status.UpgradeID = ""
+		status.UpgradeTraceParent = ""
+
+		ready, err := r.reconcileComponents(ctx, app, status.CurrentVersion)
+		if err != nil {
+			return err
+		}
+
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "UpToDate",
+			fmt.Sprintf("Running version %s", status.CurrentVersion))
+		setReadyCondition(app, ready)
+
+		return nil
+	}
+
+	if !upgradePathValid(app) {
+		hops, err := r.planUpgrade(ctx, app)
+		if err != nil {
+			return err
+		}
+		if hops == nil {
+			// The upgrade is blocked, so components stay at the current
+			// version until Spec.Version changes.
+			return r.holdComponents(ctx, app)
+		}
+		status.UpgradePath = hops
+		status.CompletedHops = 0
+		status.UpgradeID = string(uuid.NewUUID())
+		ctx, logger = logging.WithValues(ctx, logging.KeyUpgradeID, status.UpgradeID)
+		ctx = r.startUpgradeTrace(ctx, app)
+		if len(hops) > 1 {
+			logger.Info("Planned upgrade through intermediate versions", "from", status.CurrentVersion,
+				"path", hops)
+		}
+	}
+
+	if hop := status.UpgradePath[status.CompletedHops]; status.TargetVersion != hop || status.UpgradeStage == "" {
+		r.startHop(ctx, app, status.CompletedHops == 0 && needsBackup(app))
+	}
+
+	for {
+		advanced, err := r.reconcileUpgradeStage(ctx, app)
+		if err != nil || !advanced {
+			return err
+		}
+		if status.UpgradeStage == "" {
+			if status.CurrentVersion == app.Spec.Version {
+				return nil
+			}
+			r.startHop(ctx, app, false)
+		}
+	}
+}
+
+// holdComponents keeps the components of an app whose upgrade cannot start
+// running at the current version, if it has one, and records whether they are
+// ready.
+func (r *VirtoolAppReconciler) holdComponents(ctx context.Context, app *virtoolv1beta1.VirtoolApp) error {
+	if app.Status.CurrentVersion == "" {
+		setReadyCondition(app, false)
+		return nil
+	}
+
+	ready, err := r.reconcileComponents(ctx, app, app.Status.CurrentVersion)
+	if err != nil {
+		return err
+	}
+	setReadyCondition(app, ready)
+
+	return nil
+}
+
+// upgradePathValid reports whether the upgrade path in the status leads from
+// the current version to Spec.Version. Otherwise the upgrade is planned anew.
+func upgradePathValid(app *virtoolv1beta1.VirtoolApp) bool {
+	status := &app.Status
+	path := status.UpgradePath
+	hops := int(status.CompletedHops)
+
+	if len(path) == 0 || hops >= len(path) || path[len(path)-1] != app.Spec.Version {
+		return false
+	}
+
+	return hops == 0 || path[hops-1] == status.CurrentVersion
+}
+
+// startHop starts the upgrade to the next version of the upgrade path,
+// beginning with a backup if backup is set.
+func (r *VirtoolAppReconciler) startHop(ctx context.Context, app *virtoolv1beta1.VirtoolApp, backup bool) {
+	status := &app.Status
+
+	status.TargetVersion = status.UpgradePath[status.CompletedHops]
+	status.UpgradeStage = virtoolv1beta1.UpgradeStagePreUpdate
+	if backup {
+		status.UpgradeStage = virtoolv1beta1.UpgradeStageBackup
+	}
+	startUpgradeRecord(app)
+
+	log.FromContext(ctx).Info("Starting upgrade", "from", status.CurrentVersion, "to", status.TargetVersion,
+		"hop", status.CompletedHops+1, "hops", len(status.UpgradePath), "version", app.Spec.Version)
+	r.event(app, corev1.EventTypeNormal, eventUpgradeStarted, "Upgrading from %s to %s",
+		status.CurrentVersion, status.TargetVersion)
+	setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionTrue, "UpgradeStarted",
+		fmt.Sprintf("Upgrading to version %s (%d of %d towards %s)", status.TargetVersion,
+			status.CompletedHops+1, len(status.UpgradePath), app.Spec.Version))
+}
+
+// planUpgrade returns the versions the app upgrades through to reach
+// Spec.Version according to the compatibility manifest. Upgrades stop at each
+// release the manifest lists on the way, so that its update jobs run, and at
+// the versions those releases must be upgraded from.
+// It returns nil and blocks the upgrade if the manifest does not allow the
+// version change or components are pinned to versions that do not work with
+// Spec.Version.
+func (r *VirtoolAppReconciler) planUpgrade(ctx context.Context, app *virtoolv1beta1.VirtoolApp) ([]string, error) {
+	manifest, err := r.Compatibility.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifest.CheckComponents(app.Spec.Version, app.PinnedComponentVersions()); err != nil {
+		log.FromContext(ctx).Info("Refusing upgrade", "to", app.Spec.Version, "reason", err.Error())
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "IncompatibleComponents",
+			err.Error())
+		return nil, nil
+	}
+
+	hops, err := manifest.Plan(app.Status.CurrentVersion, app.Spec.Version)
+	if err != nil {
+		log.FromContext(ctx).Info("Refusing upgrade", "to", app.Spec.Version, "reason", err.Error())
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "UnsupportedUpgrade",
+			err.Error())
+		return nil, nil
+	}
+
+	return hops, nil
+}
+
+// reconcileUpgradeStage works on the current stage of an upgrade and reports
+// whether it finished and the upgrade advanced to the next stage.
+func (r *VirtoolAppReconciler) reconcileUpgradeStage(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+) (advanced bool, err error) {
+	status := &app.Status
+
+	ctx, span := r.startStageSpan(ctx, app)
+	defer func() {
+		span.SetAttributes(attrAdvanced.Bool(advanced))
+		endSpan(span, err)
+	}()
+
+	switch status.UpgradeStage {
+	case virtoolv1beta1.UpgradeStageBackup, virtoolv1beta1.UpgradeStagePreUpdate:
+		// Components keep running the current version until migrations finish.
+		if status.CurrentVersion != "" {
+			if err := r.holdComponents(ctx, app); err != nil {
+				return false, err
+			}
+		}
+
+		if status.UpgradeStage == virtoolv1beta1.UpgradeStageBackup {
+			return r.advanceAfterBackup(ctx, app)
+		}
+
+		return r.advanceAfterJobs(ctx, app, jobPhasePreUpdate, virtoolv1beta1.UpgradeStageRollout)
+
+	case virtoolv1beta1.UpgradeStageRollout:
+		ready, err := r.reconcileComponents(ctx, app, status.TargetVersion)
+		if err != nil {
+			return false, err
+		}
+		setReadyCondition(app, ready)
+
+		// Pod template fields held back by the ReportOnly drift policy may
+		// include the image, so the target version may not be running in full.
+		if drifted := rolloutBlockingObjects(app); len(drifted) > 0 {
+			setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "DriftBlocking",
+				fmt.Sprintf("Drifted fields of %s are left as they are by the ReportOnly drift policy",
+					strings.Join(drifted, ", ")))
+			return false, nil
+		}
+
+		if !ready {
+			setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionTrue, "RollingOut",
+				fmt.Sprintf("Waiting for components to become ready at version %s", status.TargetVersion))
+			return false, nil
+		}
+
+		status.UpgradeStage = virtoolv1beta1.UpgradeStagePostUpdate
+		return true, nil
+
+	case virtoolv1beta1.UpgradeStagePostUpdate:
+		if _, err := r.reconcileComponents(ctx, app, status.TargetVersion); err != nil {
+			return false, err
+		}
+
+		advanced, err = r.advanceAfterJobs(ctx, app, jobPhasePostUpdate, "")
+		if err != nil || !advanced {
+			return advanced, err
+		}
+
+		log.FromContext(ctx).Info("Finished upgrade", "from", status.CurrentVersion, "to", status.TargetVersion)
+		r.event(app, corev1.EventTypeNormal, eventUpgradeCompleted, "Upgraded from %s to %s",
+			status.CurrentVersion, status.TargetVersion)
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "UpgradeComplete",
+			fmt.Sprintf("Upgraded to version %s", status.TargetVersion))
+		if record := currentUpgradeRecord(app); record != nil {
+			now := metav1.Now()
+			record.CompletionTime = &now
+			metrics.UpgradeDuration.WithLabelValues(app.Namespace, app.Name).
+				Observe(now.Sub(record.StartTime.Time).Seconds())
+		}
+		metrics.UpgradesTotal.WithLabelValues(app.Namespace, app.Name, metrics.OutcomeSucceeded).Inc()
+		status.CurrentVersion = status.TargetVersion
+		status.TargetVersion = ""
+		status.CompletedHops++
+
+		return true, nil
+	}
+
+	return false, fmt.Errorf("unknown upgrade stage %q", status.UpgradeStage)
+}
+
+// advanceAfterJobs runs the update jobs of a phase and moves the upgrade to
+// the next stage once all of them have succeeded. A failed job blocks the
+// upgrade until the job is removed or the target version changes.
+func (r *VirtoolAppReconciler) advanceAfterJobs(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+	phase jobPhase,
+	next virtoolv1beta1.UpgradeStage,
+) (bool, error) {
+	state, failed, err := r.runUpdateJobs(ctx, app, phase, app.Status.TargetVersion)
+	if err != nil {
+		return false, err
+	}
+
+	switch state {
+	case jobStateFailed:
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionFalse, "UpdateJobFailed",
+			fmt.Sprintf("The %s job of component %s failed", phase, failed))
+		return false, nil
+	case jobStateRunning:
+		setCondition(app, virtoolv1beta1.ConditionUpgrading, metav1.ConditionTrue, "RunningUpdateJobs",
+			fmt.Sprintf("Waiting for %s jobs to complete", phase))
+		return false, nil
+	}
+
+	app.Status.UpgradeStage = next
+
+	return true, nil
+}
+
+func setReadyCondition(app *virtoolv1beta1.VirtoolApp, ready bool) {
+	if ready {
+		setCondition(app, virtoolv1beta1.ConditionReady, metav1.ConditionTrue, "ComponentsReady",
+			"All components are ready")
+		return
+	}
+
+	setCondition(app, virtoolv1beta1.ConditionReady, metav1.ConditionFalse, "ComponentsNotReady",
+		"One or more components are not ready")
+}
+
+func setCondition(app *virtoolv1beta1.VirtoolApp, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+}
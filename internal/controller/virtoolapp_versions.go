@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/image"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultVersionSkewThreshold is how long the pods of a component may run
+// more than one version before the app reports a VersionSkew, when the
+// reconciler does not set a threshold.
+const defaultVersionSkewThreshold = 15 * time.Minute
+
+// componentPods returns the pods of each component of the app that runs as a
+// Deployment, keyed by component name. The pods are selected by the selector
+// of the component's Deployment. The pods of an adopted Deployment are not
+// labelled for the manager's cache until the operator first renders its pod
+// template, so they are listed from the API server until then.
+func (r *VirtoolAppReconciler) componentPods(
+	ctx context.Context,
+	app *virtoolv1beta1.VirtoolApp,
+) (map[string][]corev1.Pod, error) {
+	components := resolveComponents(app)
+	pods := make(map[string][]corev1.Pod, len(components))
+
+	for i := range components {
+		component := &components[i]
+		if !runsAsDeployment(component) {
+			continue
+		}
+
+		var adoptionErr adoptionError
+		deployment, err := r.adoptedDeployment(ctx, app, component)
+		if errors.As(err, &adoptionErr) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var podList corev1.PodList
+		switch {
+		case deployment == nil:
+			err = r.List(ctx, &podList, r.podListOptions(app, selectorLabels(app, component))...)
+		case deployment.Spec.Template.Annotations[versionAnnotation] == "":
+			err = r.apiReader().List(ctx, &podList, client.InNamespace(app.Namespace),
+				client.MatchingLabels(deployment.Spec.Selector.MatchLabels))
+		default:
+			err = r.List(ctx, &podList, r.podListOptions(app, deployment.Spec.Selector.MatchLabels)...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing pods of component %q: %w", component.Name, err)
+		}
+		pods[component.Name] = podList.Items
+	}
+
+	return pods, nil
+}
+
+// observePodVersions returns the versions the pods of each component run,
+// keyed by component name. Pods of update jobs and pods that have finished
+// are ignored.
+func observePodVersions(ctx context.Context, pods map[string][]corev1.Pod) map[string]sets.Set[string] {
+	logger := log.FromContext(ctx)
+	observed := make(map[string]sets.Set[string])
+
+	for component := range pods {
+		for i := range pods[component] {
+			pod := &pods[component][i]
+			logger.V(logging.DebugLevel).Info("Processing pod", "pod", pod.Name, "podNamespace", pod.Namespace)
+			for _, container := range pod.Spec.Containers {
+				values := []interface{}{"pod", pod.Name, "container", container.Name, "image", container.Image}
+				if ref, err := image.Parse(container.Image); err == nil {
+					values = append(values, "repository", ref.Name(), "tag", ref.Tag, "digest", ref.Digest)
+				}
+				logger.V(logging.DebugLevel).Info("Container image tag", values...)
+			}
+
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "Job" {
+				continue
+			}
+
+			if version := podVersion(pod, component); version != "" {
+				if observed[component] == nil {
+					observed[component] = sets.New[string]()
+				}
+				observed[component].Insert(version)
+			}
+		}
+	}
+
+	return observed
+}
+
+// podVersion returns the version a pod of the named component runs: the tag
+// of the image of its main container or, for images pinned by digest alone,
+// the version the operator rendered the pod for. The main container is the
+// one named after the component, or the first for adopted pods.
+func podVersion(pod *corev1.Pod, component string) string {
+	if len(pod.Spec.Containers) == 0 {
+		return ""
+	}
+
+	main := pod.Spec.Containers[0]
+	for _, container := range pod.Spec.Containers {
+		if container.Name == component {
+			main = container
+			break
+		}
+	}
+
+	if ref, err := image.Parse(main.Image); err == nil && ref.Tag != "" {
+		return ref.Tag
+	}
+
+	return pod.Annotations[versionAnnotation]
+}
+
+// setObservedVersions records the versions the pods of each component run in
+// the app's component statuses and sets the VersionSkew condition when the
+// pods of a component have run more than one version for longer than the
+// threshold. previous holds the component statuses from before the reconcile,
+// which record when each skew started. It returns how long until a skew that
+// has started will pass the threshold, or zero if none will.
+func (r *VirtoolAppReconciler) setObservedVersions(
+	app *virtoolv1beta1.VirtoolApp,
+	previous []virtoolv1beta1.ComponentStatus,
+	observed map[string]sets.Set[string],
+) time.Duration {
+	threshold := r.VersionSkewThreshold
+	if threshold <= 0 {
+		threshold = defaultVersionSkewThreshold
+	}
+
+	skewStarted := make(map[string]*metav1.Time, len(previous))
+	for i := range previous {
+		skewStarted[previous[i].Name] = previous[i].VersionSkewTime
+	}
+
+	now := metav1.Now()
+	var skewed []string
+	var requeueAfter time.Duration
+
+	for i := range app.Status.ComponentsStatus {
+		status := &app.Status.ComponentsStatus[i]
+		versions := observed[status.Name]
+		status.ObservedVersions = sets.List(versions)
+
+		if versions.Len() < 2 {
+			status.VersionSkewTime = nil
+			continue
+		}
+
+		since := skewStarted[status.Name]
+		if since == nil {
+			since = &now
+		}
+		status.VersionSkewTime = since
+
+		if remaining := threshold - now.Sub(since.Time); remaining > 0 {
+			if requeueAfter == 0 || remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+			continue
+		}
+		skewed = append(skewed, fmt.Sprintf("%s runs %s", status.Name, strings.Join(status.ObservedVersions, ", ")))
+	}
+
+	if len(skewed) == 0 {
+		setCondition(app, virtoolv1beta1.ConditionVersionSkew, metav1.ConditionFalse, "NoSkew",
+			"The pods of each component run a single version")
+		return requeueAfter
+	}
+
+	sort.Strings(skewed)
+	setCondition(app, virtoolv1beta1.ConditionVersionSkew, metav1.ConditionTrue, "VersionSkew",
+		fmt.Sprintf("Pods have run more than one version for over %s: %s", threshold, strings.Join(skewed, "; ")))
+
+	return requeueAfter
+}
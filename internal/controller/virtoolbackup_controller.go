@@ -0,0 +1,404 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultRetention is the number of successful backups kept when a
+// VirtoolBackup does not set one.
+const defaultRetention = 7
+
+// missingAppRetryInterval is how long to wait before looking for the app of a
+// backup or restore again.
+const missingAppRetryInterval = 30 * time.Second
+
+// VirtoolBackupReconciler reconciles a VirtoolBackup object
+type VirtoolBackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Clock provides the current time. The real clock is used when nil.
+	Clock clock.PassiveClock
+
+	// APIReader reads the Secrets users create for apps, which are not in the
+	// manager's cache. The client is used when nil.
+	APIReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolbackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolbackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
+
+// Reconcile takes the backups of a VirtoolBackup when they are due, tracks
+// the Jobs taking them and prunes backups beyond the retention count.
+func (r *VirtoolBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, logger := logging.WithValues(ctx, logging.KeyBackup, req.Name, logging.KeyNamespace, req.Namespace)
+
+	var backup virtoolv1beta1.VirtoolBackup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	original := backup.Status.DeepCopy()
+
+	result, err := r.reconcileBackup(ctx, &backup)
+	if err != nil {
+		logger.Error(err, "Unable to reconcile backup")
+		return ctrl.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(original, &backup.Status) {
+		if err := r.Status().Update(ctx, &backup); err != nil {
+			logger.Error(err, "Unable to update VirtoolBackup status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *VirtoolBackupReconciler) reconcileBackup(ctx context.Context, backup *virtoolv1beta1.VirtoolBackup) (ctrl.Result, error) {
+	now := r.now()
+
+	var app virtoolv1beta1.VirtoolApp
+	err := r.Get(ctx, types.NamespacedName{Name: backup.Spec.AppName, Namespace: backup.Namespace}, &app)
+	if apierrors.IsNotFound(err) {
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "AppNotFound",
+			fmt.Sprintf("VirtoolApp %s does not exist", backup.Spec.AppName))
+		return ctrl.Result{RequeueAfter: missingAppRetryInterval}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policy := backupPolicy(backup, &app)
+	if policy == nil {
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "NoBackupPolicy",
+			fmt.Sprintf("Neither the backup nor VirtoolApp %s configure a backup policy", app.Name))
+		return ctrl.Result{}, nil
+	}
+
+	running, err := r.updateRecords(ctx, backup, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	due, next, err := nextBackup(backup, now)
+	if err != nil {
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "InvalidSchedule",
+			fmt.Sprintf("Unable to parse schedule: %v", err))
+		return ctrl.Result{}, nil
+	}
+
+	if due && running == nil {
+		running, err = r.startBackup(ctx, backup, &app, policy, now)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.pruneBackups(ctx, backup, &app, policy); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case running != nil:
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionTrue, "BackingUp",
+			fmt.Sprintf("Taking backup %s", running.ID))
+	case !next.IsZero():
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "Scheduled",
+			fmt.Sprintf("Next backup at %s", next.UTC().Format(time.RFC3339)))
+	default:
+		setBackupCondition(backup, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "Idle",
+			"No backup is in progress")
+	}
+
+	if !next.IsZero() {
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// updateRecords updates the running backups of a VirtoolBackup from their
+// Jobs and returns the one still running, if any.
+func (r *VirtoolBackupReconciler) updateRecords(
+	ctx context.Context,
+	backup *virtoolv1beta1.VirtoolBackup,
+	now time.Time,
+) (*virtoolv1beta1.BackupRecord, error) {
+	var running *virtoolv1beta1.BackupRecord
+
+	for i := range backup.Status.Backups {
+		record := &backup.Status.Backups[i]
+		if record.Phase != virtoolv1beta1.BackupPhaseRunning {
+			continue
+		}
+
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: record.ID, Namespace: backup.Namespace}, job)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("getting backup job %q: %w", record.ID, err)
+		}
+
+		switch {
+		case apierrors.IsNotFound(err), jobHasCondition(job, batchv1.JobFailed):
+			record.Phase = virtoolv1beta1.BackupPhaseFailed
+		case jobHasCondition(job, batchv1.JobComplete):
+			record.Phase = virtoolv1beta1.BackupPhaseSucceeded
+		default:
+			running = record
+			continue
+		}
+
+		completed := metav1.NewTime(now)
+		record.CompletionTime = &completed
+
+		if record.Phase == virtoolv1beta1.BackupPhaseSucceeded {
+			log.FromContext(ctx).Info("Backup succeeded", "id", record.ID)
+			setBackupCondition(backup, virtoolv1beta1.ConditionComplete, metav1.ConditionTrue, "BackupSucceeded",
+				fmt.Sprintf("Backup %s succeeded", record.ID))
+		} else {
+			log.FromContext(ctx).Info("Backup failed", "id", record.ID)
+			setBackupCondition(backup, virtoolv1beta1.ConditionComplete, metav1.ConditionFalse, "BackupFailed",
+				fmt.Sprintf("Backup %s failed", record.ID))
+		}
+	}
+
+	return running, nil
+}
+
+// startBackup launches the Job taking a new backup and records it.
+func (r *VirtoolBackupReconciler) startBackup(
+	ctx context.Context,
+	backup *virtoolv1beta1.VirtoolBackup,
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	now time.Time,
+) (*virtoolv1beta1.BackupRecord, error) {
+	scheduled := metav1.NewTime(now)
+	backup.Status.LastScheduleTime = &scheduled
+
+	databases, err := backupDatabases(ctx, r.apiReader(), app)
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		setBackupCondition(backup, virtoolv1beta1.ConditionComplete, metav1.ConditionFalse, "NoDatabases",
+			fmt.Sprintf("VirtoolApp %s has no databases to back up", app.Name))
+		return nil, nil
+	}
+
+	volumes, err := r.volumeMetadata(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	id := timestampedName(backup.Name, now)
+
+	job, err := renderBackupJob(app, policy, id, databases)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if _, err := applyObject(ctx, r.Client, nil, backup, job, nil); err != nil {
+		return nil, fmt.Errorf("creating backup job %q: %w", id, err)
+	}
+
+	log.FromContext(ctx).Info("Launched backup job", "job", id)
+
+	backup.Status.Backups = append(backup.Status.Backups, virtoolv1beta1.BackupRecord{
+		ID:         id,
+		Phase:      virtoolv1beta1.BackupPhaseRunning,
+		AppVersion: app.Status.CurrentVersion,
+		Databases:  databases,
+		Volumes:    volumes,
+		StartTime:  scheduled,
+	})
+
+	return &backup.Status.Backups[len(backup.Status.Backups)-1], nil
+}
+
+// pruneBackups deletes the oldest finished backups beyond the retention
+// count. Successful backups are deleted from the target by a prune Job.
+func (r *VirtoolBackupReconciler) pruneBackups(
+	ctx context.Context,
+	backup *virtoolv1beta1.VirtoolBackup,
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+) error {
+	retention := defaultRetention
+	if backup.Spec.Retention != nil {
+		retention = int(*backup.Spec.Retention)
+	}
+
+	counts := make(map[virtoolv1beta1.BackupPhase]int)
+	for _, record := range backup.Status.Backups {
+		counts[record.Phase]++
+	}
+
+	kept := backup.Status.Backups[:0]
+	for _, record := range backup.Status.Backups {
+		if record.Phase == virtoolv1beta1.BackupPhaseRunning || counts[record.Phase] <= retention {
+			kept = append(kept, record)
+			continue
+		}
+		counts[record.Phase]--
+
+		if record.Phase == virtoolv1beta1.BackupPhaseSucceeded {
+			job, err := renderPruneJob(app, policy, record.ID+"-prune", record.ID)
+			if err != nil {
+				return err
+			}
+			if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+				return err
+			}
+			if _, err := applyObject(ctx, r.Client, nil, backup, job, nil); err != nil {
+				return fmt.Errorf("creating prune job %q: %w", job.Name, err)
+			}
+		}
+
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: record.ID, Namespace: backup.Namespace}}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("deleting backup job %q: %w", record.ID, err)
+		}
+
+		log.FromContext(ctx).Info("Pruned backup", "id", record.ID)
+	}
+	backup.Status.Backups = kept
+
+	return nil
+}
+
+// volumeMetadata describes the persistent volume claims belonging to an app.
+func (r *VirtoolBackupReconciler) volumeMetadata(ctx context.Context, app *virtoolv1beta1.VirtoolApp) ([]virtoolv1beta1.VolumeMetadata, error) {
+	var claimList corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &claimList,
+		client.InNamespace(app.Namespace),
+		client.MatchingLabels{labelName: appName, labelInstance: app.Name},
+	); err != nil {
+		return nil, fmt.Errorf("listing persistent volume claims: %w", err)
+	}
+
+	volumes := make([]virtoolv1beta1.VolumeMetadata, 0, len(claimList.Items))
+	for i := range claimList.Items {
+		claim := &claimList.Items[i]
+
+		volume := virtoolv1beta1.VolumeMetadata{
+			ClaimName:  claim.Name,
+			VolumeName: claim.Spec.VolumeName,
+		}
+		if claim.Spec.StorageClassName != nil {
+			volume.StorageClassName = *claim.Spec.StorageClassName
+		}
+		if capacity, ok := claim.Status.Capacity[corev1.ResourceStorage]; ok {
+			volume.Capacity = capacity.String()
+		}
+
+		volumes = append(volumes, volume)
+	}
+
+	return volumes, nil
+}
+
+// nextBackup reports whether a backup is due and when the next scheduled
+// backup after now is. One-shot backups are due until they have been started
+// once and have no next time.
+func nextBackup(backup *virtoolv1beta1.VirtoolBackup, now time.Time) (bool, time.Time, error) {
+	last := backup.Status.LastScheduleTime
+
+	if backup.Spec.Schedule == "" {
+		return last == nil, time.Time{}, nil
+	}
+
+	schedule, err := cron.ParseStandard(backup.Spec.Schedule)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	since := backup.CreationTimestamp.Time
+	if last != nil {
+		since = last.Time
+	}
+
+	return !schedule.Next(since).After(now), schedule.Next(now), nil
+}
+
+// backupPolicy returns the policy a VirtoolBackup takes its backups with.
+func backupPolicy(backup *virtoolv1beta1.VirtoolBackup, app *virtoolv1beta1.VirtoolApp) *virtoolv1beta1.BackupPolicy {
+	if backup.Spec.Policy != nil {
+		return backup.Spec.Policy
+	}
+	return app.Spec.Strategy.Backup
+}
+
+func (r *VirtoolBackupReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// apiReader returns the reader used for objects outside the manager's cache.
+func (r *VirtoolBackupReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func setBackupCondition(backup *virtoolv1beta1.VirtoolBackup, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VirtoolBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&virtoolv1beta1.VirtoolBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
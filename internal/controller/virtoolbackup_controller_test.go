@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/factory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("VirtoolBackup Controller", func() {
+	const appName = "backed-up-app"
+	const backupName = "backup-sample"
+	const namespace = "default"
+
+	ctx := context.Background()
+	appNamespacedName := types.NamespacedName{Name: appName, Namespace: namespace}
+	backupNamespacedName := types.NamespacedName{Name: backupName, Namespace: namespace}
+
+	BeforeEach(func() {
+		Expect(k8sClient.Create(ctx, factory.NewVirtoolApp(appName, namespace, factory.WithBackupClaim("backups")))).To(Succeed())
+		Expect(k8sClient.Create(ctx, connectionSecret(appName, namespace))).To(Succeed())
+		Expect(k8sClient.Create(ctx, &virtoolv1beta1.VirtoolBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: backupName, Namespace: namespace},
+			Spec:       virtoolv1beta1.VirtoolBackupSpec{AppName: appName},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		backup := &virtoolv1beta1.VirtoolBackup{ObjectMeta: metav1.ObjectMeta{Name: backupName, Namespace: namespace}}
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, backup))).To(Succeed())
+		cleanupResource(ctx, appNamespacedName)
+		cleanupOwnedObjects(ctx, namespace, appName)
+	})
+
+	It("should take a one-shot backup and track its Job", func() {
+		reconciler := &VirtoolBackupReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
+		reconcileBackup := func() *virtoolv1beta1.VirtoolBackup {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: backupNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var backup virtoolv1beta1.VirtoolBackup
+			Expect(k8sClient.Get(ctx, backupNamespacedName, &backup)).To(Succeed())
+			return &backup
+		}
+
+		By("launching a backup Job")
+		backup := reconcileBackup()
+		Expect(backup.Status.Backups).To(HaveLen(1))
+		record := backup.Status.Backups[0]
+		Expect(record.Phase).To(Equal(virtoolv1beta1.BackupPhaseRunning))
+		Expect(record.Databases).To(Equal([]string{"postgres"}))
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, virtoolv1beta1.ConditionProgressing)).To(BeTrue())
+
+		var job batchv1.Job
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: record.ID, Namespace: namespace}, &job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+
+		By("recording the backup once the Job completes")
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+		backup = reconcileBackup()
+		Expect(backup.Status.Backups).To(HaveLen(1))
+		Expect(backup.Status.Backups[0].Phase).To(Equal(virtoolv1beta1.BackupPhaseSucceeded))
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, virtoolv1beta1.ConditionComplete)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(backup.Status.Conditions, virtoolv1beta1.ConditionProgressing)).To(BeTrue())
+
+		By("not taking another backup without a schedule")
+		backup = reconcileBackup()
+		Expect(backup.Status.Backups).To(HaveLen(1))
+	})
+})
+
+// connectionSecret returns a connection secret for an app providing only a
+// PostgreSQL connection string.
+func connectionSecret(appName, namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      appName + "-connections",
+			Namespace: namespace,
+			Labels:    map[string]string{labelInstance: appName},
+		},
+		StringData: map[string]string{"postgres": "postgresql://virtool@postgres:5432/virtool"},
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// VirtoolComponentReconciler reconciles a VirtoolComponent object
+type VirtoolComponentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder records events on VirtoolComponents. No events are recorded
+	// when nil.
+	Recorder record.EventRecorder
+
+	// APIReader reads Deployments adopted by components, which are not in
+	// the manager's cache until the operator has labelled them. The client
+	// is used when nil.
+	APIReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolcomponents,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolcomponents/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolcomponents/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile renders the Deployment of a VirtoolComponent and records the
+// state of its pods in the VirtoolComponent status, from which the VirtoolApp
+// controller aggregates the status of the app.
+func (r *VirtoolComponentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, logger := logging.WithValues(ctx, logging.KeyComponent, req.Name, logging.KeyNamespace, req.Namespace)
+
+	var component virtoolv1beta1.VirtoolComponent
+	if err := r.Get(ctx, req.NamespacedName, &component); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := component.Status.DeepCopy()
+
+	key := types.NamespacedName{Name: component.Name, Namespace: component.Namespace}
+	if component.Spec.DeploymentName != "" {
+		key.Name = component.Spec.DeploymentName
+	}
+
+	var previousVersion string
+	current := &appsv1.Deployment{}
+	err := r.Get(ctx, key, current)
+	if apierrors.IsNotFound(err) && component.Spec.DeploymentName != "" {
+		// An adopted Deployment is not in the cache until it is labelled.
+		err = r.apiReader().Get(ctx, key, current)
+	}
+	if err == nil {
+		previousVersion = current.Annotations[versionAnnotation]
+	} else if apierrors.IsNotFound(err) {
+		current = nil
+	} else {
+		return ctrl.Result{}, fmt.Errorf("getting deployment %q: %w", key.Name, err)
+	}
+
+	deployment := &appsv1.Deployment{}
+	drift := &driftTracker{policy: component.Spec.DriftPolicy, records: &component.Status.Drift}
+
+	// The pod template of an adopted Deployment has not been rendered by the
+	// operator yet. It is left as it is while the component stays at the
+	// version it was adopted at, so that adopting it does not restart pods.
+	adopting := current != nil && current.Spec.Template.Annotations[versionAnnotation] == ""
+	var result controllerutil.OperationResult
+	if spec := &component.Spec; adopting && spec.AdoptedVersion == spec.Version && !spec.Suspended {
+		result, err = r.takeOverDeployment(ctx, &component, key, deployment, drift)
+	} else {
+		deployment.Name = key.Name
+		deployment.Namespace = key.Namespace
+		mutateDeployment(&component, deployment, current)
+		if err := controllerutil.SetControllerReference(&component, deployment, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if adopting {
+			// The fields written by hand are taken over from their managers
+			// the first time, rather than reported as drift.
+			result, err = applyObject(ctx, r.Client, nil, &component, deployment, nil)
+		} else {
+			result, err = applyObject(ctx, r.Client, r.Recorder, &component, deployment, drift)
+		}
+	}
+	if err != nil {
+		logger.Error(err, "Unable to reconcile deployment")
+		return ctrl.Result{}, fmt.Errorf("reconciling deployment %q: %w", key.Name, err)
+	}
+
+	// Updates only count as drift when the spec of the component has not
+	// changed since it was last reconciled.
+	if result == controllerutil.OperationResultUpdated && previousVersion == component.Spec.Version &&
+		component.Status.ObservedGeneration == component.Generation {
+		logger.Info("Corrected drift", "kind", "Deployment", "name", deployment.Name)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(&component, corev1.EventTypeNormal, eventDriftCorrected,
+				"Reverted changes to Deployment %s", deployment.Name)
+		}
+	}
+
+	setComponentStatus(&component, deployment)
+
+	if !equality.Semantic.DeepEqual(original, &component.Status) {
+		if err := r.Status().Update(ctx, &component); err != nil {
+			logger.Error(err, "Unable to update status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VirtoolComponentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&virtoolv1beta1.VirtoolComponent{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+}
+
+// mutateDeployment renders the desired state of a component into its
+// Deployment. Only the fields managed by the operator are set, so that
+// server-side defaults and fields set by others are left to their managers.
+// current is the existing Deployment, if any, whose selector is immutable and
+// kept as it is.
+func mutateDeployment(component *virtoolv1beta1.VirtoolComponent, deployment, current *appsv1.Deployment) {
+	spec := &component.Spec
+
+	deployment.Labels = labelsFor(spec.AppName, spec.ComponentName)
+	metav1.SetMetaDataAnnotation(&deployment.ObjectMeta, versionAnnotation, spec.Version)
+
+	replicas := spec.Replicas
+	if spec.Suspended {
+		replicas = 0
+	}
+	deployment.Spec.Replicas = &replicas
+
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selectorLabelsFor(spec.AppName, spec.ComponentName)}
+	if current != nil && current.Spec.Selector != nil {
+		deployment.Spec.Selector = current.Spec.Selector.DeepCopy()
+	}
+
+	template := &deployment.Spec.Template
+	template.Labels = labelsFor(spec.AppName, spec.ComponentName)
+	for key, value := range deployment.Spec.Selector.MatchLabels {
+		template.Labels[key] = value
+	}
+	metav1.SetMetaDataAnnotation(&template.ObjectMeta, versionAnnotation, spec.Version)
+
+	container := findContainer(&template.Spec, mainContainerName(component, current))
+	container.Image = imageForVersion(spec.Image, spec.Version)
+	container.Command = spec.Command
+	container.Args = spec.Args
+	container.Env = spec.Env
+	container.Resources = *spec.Resources.DeepCopy()
+
+	if probes := spec.Probes; probes != nil {
+		container.LivenessProbe = probes.Liveness.DeepCopy()
+		container.ReadinessProbe = probes.Readiness.DeepCopy()
+		container.StartupProbe = probes.Startup.DeepCopy()
+	}
+
+	if spec.Port != 0 {
+		container.Ports = []corev1.ContainerPort{{
+			Name:          "http",
+			ContainerPort: spec.Port,
+			Protocol:      corev1.ProtocolTCP,
+		}}
+	}
+}
+
+// mainContainerName returns the name of the container the component runs
+// in. The main container of an adopted Deployment, the first in its pod
+// template, keeps its name so that it is updated in place instead of being
+// joined by a second one.
+func mainContainerName(component *virtoolv1beta1.VirtoolComponent, current *appsv1.Deployment) string {
+	name := component.Spec.ComponentName
+	if component.Spec.DeploymentName == "" || current == nil || len(current.Spec.Template.Spec.Containers) == 0 {
+		return name
+	}
+
+	for _, container := range current.Spec.Template.Spec.Containers {
+		if container.Name == name {
+			return name
+		}
+	}
+
+	return current.Spec.Template.Spec.Containers[0].Name
+}
+
+// takeOverDeployment adds the operator's labels, version annotation and a
+// controller reference to an adopted Deployment, leaving its spec and pods
+// as they are, and reads the result into deployment.
+func (r *VirtoolComponentReconciler) takeOverDeployment(
+	ctx context.Context,
+	component *virtoolv1beta1.VirtoolComponent,
+	key types.NamespacedName,
+	deployment *appsv1.Deployment,
+	drift *driftTracker,
+) (controllerutil.OperationResult, error) {
+	obj, err := takeoverObject(r.Scheme, component, appsv1.SchemeGroupVersion.WithKind("Deployment"), key,
+		labelsFor(component.Spec.AppName, component.Spec.ComponentName),
+		map[string]string{versionAnnotation: component.Spec.Version})
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	result, err := applyObject(ctx, r.Client, r.Recorder, component, obj, drift)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	return result, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment)
+}
+
+// apiReader returns the reader used for objects outside the manager's cache.
+func (r *VirtoolComponentReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// setComponentStatus records the state of the component's Deployment in its
+// status.
+func setComponentStatus(component *virtoolv1beta1.VirtoolComponent, deployment *appsv1.Deployment) {
+	status := &component.Status
+	status.ObservedGeneration = component.Generation
+	status.CurrentVersion = deployment.Annotations[versionAnnotation]
+	status.Replicas = deployment.Status.Replicas
+	status.ReadyReplicas = deployment.Status.ReadyReplicas
+	status.UpdatedReplicas = deployment.Status.UpdatedReplicas
+	status.Selector = labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels).String()
+
+	condition := metav1.Condition{
+		Type:               virtoolv1beta1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RollingOut",
+		Message:            fmt.Sprintf("%d of %d pods are updated and ready", deployment.Status.ReadyReplicas, *deployment.Spec.Replicas),
+		ObservedGeneration: component.Generation,
+	}
+	if deploymentReady(deployment) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RolledOut"
+		condition.Message = fmt.Sprintf("All %d pods are updated and ready", *deployment.Spec.Replicas)
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// deploymentReady reports whether every replica of the Deployment has been
+// updated to the latest template and passes its readiness probe.
+func deploymentReady(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.ReadyReplicas == replicas &&
+		deployment.Status.Replicas == replicas
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/internal/logging"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// restoreFinalizer releases the app of a VirtoolRestore when the restore is
+// deleted.
+const restoreFinalizer = "virtool.ca/restore"
+
+// scaleDownPollInterval is how often a restore checks whether the app's
+// components have stopped.
+const scaleDownPollInterval = 5 * time.Second
+
+// VirtoolRestoreReconciler reconciles a VirtoolRestore object
+type VirtoolRestoreReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolrestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolrestores/finalizers,verbs=update
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolbackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=virtool.virtool.ca,resources=virtoolapps,verbs=get;list;watch;update;patch
+
+// Reconcile restores a backup into a VirtoolApp. The app's components are
+// scaled down first, the databases are then restored by a Job and the
+// components are scaled back up once it finishes, whether or not it succeeds.
+// A backup is only restored into the app version it was taken from.
+func (r *VirtoolRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, logger := logging.WithValues(ctx, logging.KeyRestore, req.Name, logging.KeyNamespace, req.Namespace)
+
+	var restore virtoolv1beta1.VirtoolRestore
+	if err := r.Get(ctx, req.NamespacedName, &restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !restore.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &restore)
+	}
+
+	if !controllerutil.ContainsFinalizer(&restore, restoreFinalizer) {
+		controllerutil.AddFinalizer(&restore, restoreFinalizer)
+		if err := r.Update(ctx, &restore); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	original := restore.Status.DeepCopy()
+
+	result, err := r.reconcileRestore(ctx, &restore)
+	if err != nil {
+		logger.Error(err, "Unable to reconcile restore")
+		return ctrl.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(original, &restore.Status) {
+		if err := r.Status().Update(ctx, &restore); err != nil {
+			logger.Error(err, "Unable to update VirtoolRestore status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (r *VirtoolRestoreReconciler) reconcileRestore(ctx context.Context, restore *virtoolv1beta1.VirtoolRestore) (ctrl.Result, error) {
+	status := &restore.Status
+
+	if status.Phase == virtoolv1beta1.RestorePhaseSucceeded || status.Phase == virtoolv1beta1.RestorePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	var app virtoolv1beta1.VirtoolApp
+	err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.AppName, Namespace: restore.Namespace}, &app)
+	if apierrors.IsNotFound(err) {
+		setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "AppNotFound",
+			fmt.Sprintf("VirtoolApp %s does not exist", restore.Spec.AppName))
+		return ctrl.Result{RequeueAfter: missingAppRetryInterval}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var backup virtoolv1beta1.VirtoolBackup
+	if err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.BackupName, Namespace: restore.Namespace}, &backup); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.fail(ctx, restore, &app, "BackupNotFound",
+				fmt.Sprintf("VirtoolBackup %s does not exist", restore.Spec.BackupName))
+		}
+		return ctrl.Result{}, err
+	}
+
+	id := restore.Spec.BackupID
+	if status.BackupID != "" {
+		id = status.BackupID
+	}
+
+	record := findBackupRecord(&backup, id)
+	if record == nil {
+		return ctrl.Result{}, r.fail(ctx, restore, &app, "BackupNotFound",
+			fmt.Sprintf("VirtoolBackup %s has no successful backup to restore", backup.Name))
+	}
+
+	policy := backupPolicy(&backup, &app)
+	if policy == nil {
+		return ctrl.Result{}, r.fail(ctx, restore, &app, "NoBackupPolicy",
+			fmt.Sprintf("Neither VirtoolBackup %s nor VirtoolApp %s configure a backup policy", backup.Name, app.Name))
+	}
+
+	if status.Phase == "" {
+		// The schema of the databases follows the app version, so a backup
+		// is only restored into the version it was taken from.
+		current := app.Status.CurrentVersion
+		if record.AppVersion != "" && current != "" && record.AppVersion != current {
+			return ctrl.Result{}, r.fail(ctx, restore, &app, "VersionMismatch",
+				fmt.Sprintf("Backup %s was taken from version %s but %s is running version %s",
+					record.ID, record.AppVersion, app.Name, current))
+		}
+
+		now := metav1.Now()
+		status.StartTime = &now
+		status.BackupID = record.ID
+		status.Phase = virtoolv1beta1.RestorePhaseScalingDown
+	}
+
+	switch status.Phase {
+	case virtoolv1beta1.RestorePhaseScalingDown:
+		if holder, ok := app.Annotations[restoreAnnotation]; ok && holder != restore.Name {
+			setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "AppBusy",
+				fmt.Sprintf("VirtoolApp %s is being restored by %s", app.Name, holder))
+			return ctrl.Result{RequeueAfter: scaleDownPollInterval}, nil
+		}
+
+		if err := r.setRestoreAnnotation(ctx, &app, restore.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		stopped, err := deploymentsStopped(ctx, r.Client, &app)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !stopped {
+			setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionTrue, "ScalingDown",
+				fmt.Sprintf("Waiting for the components of %s to stop", app.Name))
+			return ctrl.Result{RequeueAfter: scaleDownPollInterval}, nil
+		}
+
+		status.Phase = virtoolv1beta1.RestorePhaseRestoring
+		fallthrough
+
+	case virtoolv1beta1.RestorePhaseRestoring:
+		job, err := r.ensureRestoreJob(ctx, restore, &app, policy, record)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		switch {
+		case jobHasCondition(job, batchv1.JobFailed):
+			return ctrl.Result{}, r.fail(ctx, restore, &app, "RestoreFailed",
+				fmt.Sprintf("Restoring backup %s failed", record.ID))
+		case !jobHasCondition(job, batchv1.JobComplete):
+			setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionTrue, "Restoring",
+				fmt.Sprintf("Restoring backup %s", record.ID))
+			return ctrl.Result{}, nil
+		}
+
+		if err := r.setRestoreAnnotation(ctx, &app, ""); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		log.FromContext(ctx).Info("Restored backup", "id", record.ID, logging.KeyApp, app.Name)
+
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Phase = virtoolv1beta1.RestorePhaseSucceeded
+		setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, "Restored",
+			"The restore has finished")
+		setRestoreCondition(restore, virtoolv1beta1.ConditionComplete, metav1.ConditionTrue, "Restored",
+			fmt.Sprintf("Restored backup %s into %s", record.ID, app.Name))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ensureRestoreJob returns the Job restoring a backup, creating it if it does
+// not exist.
+func (r *VirtoolRestoreReconciler) ensureRestoreJob(
+	ctx context.Context,
+	restore *virtoolv1beta1.VirtoolRestore,
+	app *virtoolv1beta1.VirtoolApp,
+	policy *virtoolv1beta1.BackupPolicy,
+	record *virtoolv1beta1.BackupRecord,
+) (*batchv1.Job, error) {
+	name := restore.Name + "-" + restoreComponent
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: restore.Namespace}, job)
+	if err == nil {
+		return job, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting restore job %q: %w", name, err)
+	}
+
+	job, err = renderRestoreJob(app, policy, name, record.ID, record.Databases)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if _, err := applyObject(ctx, r.Client, nil, restore, job, nil); err != nil {
+		return nil, fmt.Errorf("creating restore job %q: %w", name, err)
+	}
+
+	log.FromContext(ctx).Info("Launched restore job", "job", name, "id", record.ID)
+
+	return job, nil
+}
+
+// setRestoreAnnotation sets the restore annotation of an app to the name of a
+// restore, or removes it when name is empty.
+func (r *VirtoolRestoreReconciler) setRestoreAnnotation(ctx context.Context, app *virtoolv1beta1.VirtoolApp, name string) error {
+	current, ok := app.Annotations[restoreAnnotation]
+	if (name == "" && !ok) || (name != "" && current == name) {
+		return nil
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	if name == "" {
+		delete(app.Annotations, restoreAnnotation)
+	} else {
+		metav1.SetMetaDataAnnotation(&app.ObjectMeta, restoreAnnotation, name)
+	}
+
+	if err := r.Patch(ctx, app, patch); err != nil {
+		return fmt.Errorf("updating restore annotation of %q: %w", app.Name, err)
+	}
+
+	return nil
+}
+
+// finalize releases the app of a deleted restore so that its components are
+// scaled back up.
+func (r *VirtoolRestoreReconciler) finalize(ctx context.Context, restore *virtoolv1beta1.VirtoolRestore) error {
+	if !controllerutil.ContainsFinalizer(restore, restoreFinalizer) {
+		return nil
+	}
+
+	var app virtoolv1beta1.VirtoolApp
+	err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.AppName, Namespace: restore.Namespace}, &app)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && app.Annotations[restoreAnnotation] == restore.Name {
+		if err := r.setRestoreAnnotation(ctx, &app, ""); err != nil {
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(restore, restoreFinalizer)
+
+	return r.Update(ctx, restore)
+}
+
+// fail marks a restore as failed and releases the app if the restore holds it,
+// so that its components are scaled back up instead of waiting indefinitely
+// for a restore that will not finish.
+func (r *VirtoolRestoreReconciler) fail(
+	ctx context.Context,
+	restore *virtoolv1beta1.VirtoolRestore,
+	app *virtoolv1beta1.VirtoolApp,
+	reason, message string,
+) error {
+	if app.Annotations[restoreAnnotation] == restore.Name {
+		if err := r.setRestoreAnnotation(ctx, app, ""); err != nil {
+			return err
+		}
+	}
+
+	log.FromContext(ctx).Info("Restore failed", "reason", reason)
+
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	restore.Status.Phase = virtoolv1beta1.RestorePhaseFailed
+	setRestoreCondition(restore, virtoolv1beta1.ConditionProgressing, metav1.ConditionFalse, reason, message)
+	setRestoreCondition(restore, virtoolv1beta1.ConditionComplete, metav1.ConditionFalse, reason, message)
+
+	return nil
+}
+
+// findBackupRecord returns the successful backup with the given identifier,
+// or the most recent successful backup if id is empty.
+func findBackupRecord(backup *virtoolv1beta1.VirtoolBackup, id string) *virtoolv1beta1.BackupRecord {
+	for i := len(backup.Status.Backups) - 1; i >= 0; i-- {
+		record := &backup.Status.Backups[i]
+		if record.Phase != virtoolv1beta1.BackupPhaseSucceeded {
+			continue
+		}
+		if id == "" || record.ID == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func setRestoreCondition(restore *virtoolv1beta1.VirtoolRestore, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: restore.Generation,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VirtoolRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&virtoolv1beta1.VirtoolRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
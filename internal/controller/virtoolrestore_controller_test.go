@@ -0,0 +1,193 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	virtoolv1beta1 "github.com/bryce-davidson/virtool-operator/api/v1beta1"
+	"github.com/bryce-davidson/virtool-operator/factory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("VirtoolRestore Controller", func() {
+	const appName = "restored-app"
+	const backupName = "restore-source"
+	const restoreName = "restore-sample"
+	const backupID = "restore-source-20240101-030000"
+	const namespace = "default"
+
+	ctx := context.Background()
+	appNamespacedName := types.NamespacedName{Name: appName, Namespace: namespace}
+	restoreNamespacedName := types.NamespacedName{Name: restoreName, Namespace: namespace}
+
+	var reconciler *VirtoolRestoreReconciler
+
+	BeforeEach(func() {
+		reconciler = &VirtoolRestoreReconciler{
+			Client: k8sClient,
+			Scheme: k8sClient.Scheme(),
+		}
+
+		Expect(k8sClient.Create(ctx, factory.NewVirtoolApp(appName, namespace, factory.WithBackupClaim("backups")))).To(Succeed())
+		Expect(k8sClient.Create(ctx, connectionSecret(appName, namespace))).To(Succeed())
+
+		backup := &virtoolv1beta1.VirtoolBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: backupName, Namespace: namespace},
+			Spec:       virtoolv1beta1.VirtoolBackupSpec{AppName: appName},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+		backup.Status.Backups = []virtoolv1beta1.BackupRecord{{
+			ID:        backupID,
+			Phase:     virtoolv1beta1.BackupPhaseSucceeded,
+			Databases: []string{"postgres"},
+			StartTime: metav1.Now(),
+		}}
+		Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+
+		Expect(k8sClient.Create(ctx, &virtoolv1beta1.VirtoolRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: restoreName, Namespace: namespace},
+			Spec:       virtoolv1beta1.VirtoolRestoreSpec{BackupName: backupName, AppName: appName},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		restore := &virtoolv1beta1.VirtoolRestore{ObjectMeta: metav1.ObjectMeta{Name: restoreName, Namespace: namespace}}
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, restore))).To(Succeed())
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+
+		backup := &virtoolv1beta1.VirtoolBackup{ObjectMeta: metav1.ObjectMeta{Name: backupName, Namespace: namespace}}
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, backup))).To(Succeed())
+		cleanupResource(ctx, appNamespacedName)
+		cleanupOwnedObjects(ctx, namespace, appName)
+	})
+
+	It("should suspend the app while restoring the latest backup", func() {
+		reconcileRestore := func() *virtoolv1beta1.VirtoolRestore {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var restore virtoolv1beta1.VirtoolRestore
+			Expect(k8sClient.Get(ctx, restoreNamespacedName, &restore)).To(Succeed())
+			return &restore
+		}
+
+		By("suspending the app and launching a restore Job")
+		restore := reconcileRestore()
+		Expect(restore.Status.BackupID).To(Equal(backupID))
+		Expect(restore.Status.Phase).To(Equal(virtoolv1beta1.RestorePhaseRestoring))
+
+		var app virtoolv1beta1.VirtoolApp
+		Expect(k8sClient.Get(ctx, appNamespacedName, &app)).To(Succeed())
+		Expect(app.Annotations).To(HaveKeyWithValue(restoreAnnotation, restoreName))
+
+		var job batchv1.Job
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: restoreName + "-restore", Namespace: namespace}, &job)).To(Succeed())
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command[2]).To(ContainSubstring("pg_restore"))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "BACKUP_DIR", Value: "/backup/" + backupID}))
+
+		By("resuming the app once the restore completes")
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+		restore = reconcileRestore()
+		Expect(restore.Status.Phase).To(Equal(virtoolv1beta1.RestorePhaseSucceeded))
+		Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, virtoolv1beta1.ConditionComplete)).To(BeTrue())
+
+		Expect(k8sClient.Get(ctx, appNamespacedName, &app)).To(Succeed())
+		Expect(app.Annotations).NotTo(HaveKey(restoreAnnotation))
+	})
+
+	It("should fail when the backup does not exist", func() {
+		var restore virtoolv1beta1.VirtoolRestore
+		Expect(k8sClient.Get(ctx, restoreNamespacedName, &restore)).To(Succeed())
+		restore.Spec.BackupID = "missing"
+		Expect(k8sClient.Update(ctx, &restore)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, restoreNamespacedName, &restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(virtoolv1beta1.RestorePhaseFailed))
+		condition := meta.FindStatusCondition(restore.Status.Conditions, virtoolv1beta1.ConditionComplete)
+		Expect(condition.Reason).To(Equal("BackupNotFound"))
+
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: restoreName + "-restore", Namespace: namespace}, &batchv1.Job{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should refuse a backup taken from another app version", func() {
+		var backup virtoolv1beta1.VirtoolBackup
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: backupName, Namespace: namespace}, &backup)).To(Succeed())
+		backup.Status.Backups[0].AppVersion = "1.0.0"
+		Expect(k8sClient.Status().Update(ctx, &backup)).To(Succeed())
+
+		var app virtoolv1beta1.VirtoolApp
+		Expect(k8sClient.Get(ctx, appNamespacedName, &app)).To(Succeed())
+		app.Status.CurrentVersion = "1.1.0"
+		Expect(k8sClient.Status().Update(ctx, &app)).To(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+
+		var restore virtoolv1beta1.VirtoolRestore
+		Expect(k8sClient.Get(ctx, restoreNamespacedName, &restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(virtoolv1beta1.RestorePhaseFailed))
+		condition := meta.FindStatusCondition(restore.Status.Conditions, virtoolv1beta1.ConditionComplete)
+		Expect(condition.Reason).To(Equal("VersionMismatch"))
+
+		Expect(k8sClient.Get(ctx, appNamespacedName, &app)).To(Succeed())
+		Expect(app.Annotations).NotTo(HaveKey(restoreAnnotation))
+
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: restoreName + "-restore", Namespace: namespace}, &batchv1.Job{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should resume the app when the restore fails", func() {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+
+		var job batchv1.Job
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: restoreName + "-restore", Namespace: namespace}, &job)).To(Succeed())
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreNamespacedName})
+		Expect(err).NotTo(HaveOccurred())
+
+		var restore virtoolv1beta1.VirtoolRestore
+		Expect(k8sClient.Get(ctx, restoreNamespacedName, &restore)).To(Succeed())
+		Expect(restore.Status.Phase).To(Equal(virtoolv1beta1.RestorePhaseFailed))
+		condition := meta.FindStatusCondition(restore.Status.Conditions, virtoolv1beta1.ConditionComplete)
+		Expect(condition.Reason).To(Equal("RestoreFailed"))
+
+		var app virtoolv1beta1.VirtoolApp
+		Expect(k8sClient.Get(ctx, appNamespacedName, &app)).To(Succeed())
+		Expect(app.Annotations).NotTo(HaveKey(restoreAnnotation))
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dependency checks that the datastores Virtool depends on are
+// reachable and answering their protocol before the operator relies on them.
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single check may take.
+const DefaultTimeout = 5 * time.Second
+
+// Checker checks that the datastore at a connection string is reachable and
+// responding.
+type Checker interface {
+	Check(ctx context.Context, connectionString string) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context, connectionString string) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context, connectionString string) error {
+	return f(ctx, connectionString)
+}
+
+// Checkers holds a Checker for each datastore, keyed by datastore name.
+type Checkers map[string]Checker
+
+// DefaultCheckers returns checkers that speak the protocol of each datastore
+// used by Virtool.
+func DefaultCheckers() Checkers {
+	return Checkers{
+		"postgres": PostgresChecker{},
+		"mongodb":  MongoDBChecker{},
+		"redis":    RedisChecker{},
+	}
+}
+
+// dial opens a TCP connection to the address, bounded by DefaultTimeout and
+// the context's deadline. The returned connection has its deadline set.
+func dial(ctx context.Context, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// parseAddress returns the host and port of the first host in a connection
+// string, falling back to defaultPort when none is given, and the parsed URL.
+func parseAddress(connectionString string, defaultPort string) (string, *url.URL, error) {
+	// The parse error is not wrapped as it includes the connection string,
+	// which may hold credentials.
+	u, err := url.Parse(connectionString)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid connection string")
+	}
+
+	host := u.Host
+	if i := strings.Index(host, ","); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return "", nil, fmt.Errorf("connection string has no host")
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, defaultPort)
+	}
+
+	return host, u, nil
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// standIn serves each accepted connection with handle until the test ends
+// and returns its address.
+func standIn(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// closedAddress returns an address nothing is listening on.
+func closedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	return address
+}
+
+func postgresStandIn(conn net.Conn) {
+	request := make([]byte, 8)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	if binary.BigEndian.Uint32(request[4:8]) == sslRequestCode {
+		conn.Write([]byte("N"))
+	}
+}
+
+func redisStandIn(password string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			args, err := readRESP(reader)
+			if err != nil {
+				return
+			}
+			switch {
+			case args[0] == "AUTH" && args[len(args)-1] == password:
+				conn.Write([]byte("+OK\r\n"))
+			case args[0] == "AUTH":
+				conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+			case args[0] == "PING":
+				conn.Write([]byte("+PONG\r\n"))
+			}
+		}
+	}
+}
+
+func readRESP(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	if _, err := fmt.Sscanf(line, "*%d", &count); err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimRight(arg, "\r\n"))
+	}
+
+	return args, nil
+}
+
+func mongoDBStandIn(conn net.Conn) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(binary.LittleEndian.Uint32(header[0:4])-16)); err != nil {
+		return
+	}
+
+	reply := make([]byte, 16)
+	binary.LittleEndian.PutUint32(reply[0:4], 16)
+	binary.LittleEndian.PutUint32(reply[12:16], opMsg)
+	conn.Write(reply)
+}
+
+func httpStandIn(conn net.Conn) {
+	conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name             string
+		checker          Checker
+		connectionString func(t *testing.T) string
+		wantErr          bool
+	}{
+		{
+			name:    "postgres",
+			checker: PostgresChecker{},
+			connectionString: func(t *testing.T) string {
+				return "postgresql://virtool:secret@" + standIn(t, postgresStandIn) + "/virtool"
+			},
+		},
+		{
+			name:    "postgres unreachable",
+			checker: PostgresChecker{},
+			connectionString: func(t *testing.T) string {
+				return "postgresql://virtool:secret@" + closedAddress(t) + "/virtool"
+			},
+			wantErr: true,
+		},
+		{
+			name:    "postgres wrong protocol",
+			checker: PostgresChecker{},
+			connectionString: func(t *testing.T) string {
+				return "postgresql://" + standIn(t, httpStandIn) + "/virtool"
+			},
+			wantErr: true,
+		},
+		{
+			name:    "mongodb",
+			checker: MongoDBChecker{},
+			connectionString: func(t *testing.T) string {
+				return "mongodb://virtool:secret@" + standIn(t, mongoDBStandIn) + "/virtool?authSource=admin"
+			},
+		},
+		{
+			name:    "mongodb wrong protocol",
+			checker: MongoDBChecker{},
+			connectionString: func(t *testing.T) string {
+				return "mongodb://" + standIn(t, httpStandIn) + "/virtool"
+			},
+			wantErr: true,
+		},
+		{
+			name:    "redis",
+			checker: RedisChecker{},
+			connectionString: func(t *testing.T) string {
+				return "redis://:secret@" + standIn(t, redisStandIn("secret"))
+			},
+		},
+		{
+			name:    "redis wrong password",
+			checker: RedisChecker{},
+			connectionString: func(t *testing.T) string {
+				return "redis://:wrong@" + standIn(t, redisStandIn("secret"))
+			},
+			wantErr: true,
+		},
+		{
+			name:    "redis unreachable",
+			checker: RedisChecker{},
+			connectionString: func(t *testing.T) string {
+				return "redis://" + closedAddress(t)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.checker.Check(ctx, tt.connectionString(t))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
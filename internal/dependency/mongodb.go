@@ -0,0 +1,102 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// opMsg is the opcode of a MongoDB OP_MSG message.
+const opMsg = 2013
+
+// MongoDBChecker checks a MongoDB server by sending a hello command, which
+// servers answer without authentication.
+type MongoDBChecker struct{}
+
+// Check implements Checker.
+func (MongoDBChecker) Check(ctx context.Context, connectionString string) error {
+	address, u, err := parseAddress(connectionString, "27017")
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "mongodb+srv" {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "mongodb", "tcp", u.Hostname())
+		if err != nil {
+			return fmt.Errorf("resolving srv record: %w", err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("no srv records for %s", u.Hostname())
+		}
+		address = net.JoinHostPort(records[0].Target, strconv.Itoa(int(records[0].Port)))
+	}
+
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(helloMessage()); err != nil {
+		return fmt.Errorf("sending hello: %w", err)
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("reading hello reply: %w", err)
+	}
+
+	if opCode := binary.LittleEndian.Uint32(header[12:16]); opCode != opMsg {
+		return fmt.Errorf("unexpected opcode %d in hello reply", opCode)
+	}
+
+	return nil
+}
+
+// helloMessage returns an OP_MSG message carrying {hello: 1, $db: "admin"}.
+func helloMessage() []byte {
+	var elements []byte
+	elements = append(elements, 0x10)
+	elements = append(elements, "hello\x00"...)
+	elements = binary.LittleEndian.AppendUint32(elements, 1)
+	elements = append(elements, 0x02)
+	elements = append(elements, "$db\x00"...)
+	elements = binary.LittleEndian.AppendUint32(elements, uint32(len("admin")+1))
+	elements = append(elements, "admin\x00"...)
+
+	document := binary.LittleEndian.AppendUint32(nil, uint32(4+len(elements)+1))
+	document = append(document, elements...)
+	document = append(document, 0x00)
+
+	length := 16 + 4 + 1 + len(document)
+
+	message := make([]byte, 0, length)
+	message = binary.LittleEndian.AppendUint32(message, uint32(length))
+	message = binary.LittleEndian.AppendUint32(message, 1)
+	message = binary.LittleEndian.AppendUint32(message, 0)
+	message = binary.LittleEndian.AppendUint32(message, opMsg)
+	message = binary.LittleEndian.AppendUint32(message, 0)
+	message = append(message, 0x00)
+	message = append(message, document...)
+
+	return message
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// sslRequestCode is the request code of a PostgreSQL SSLRequest message.
+const sslRequestCode = 80877103
+
+// PostgresChecker checks a PostgreSQL server by sending an SSLRequest, which
+// every server answers with a single byte before authentication.
+type PostgresChecker struct{}
+
+// Check implements Checker.
+func (PostgresChecker) Check(ctx context.Context, connectionString string) error {
+	address, _, err := parseAddress(connectionString, "5432")
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], sslRequestCode)
+
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("sending ssl request: %w", err)
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return fmt.Errorf("reading ssl response: %w", err)
+	}
+
+	if response[0] != 'S' && response[0] != 'N' {
+		return fmt.Errorf("unexpected response %q to ssl request", response[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RedisChecker checks a Redis server by authenticating, if the connection
+// string has a password, and sending a PING.
+type RedisChecker struct{}
+
+// Check implements Checker.
+func (RedisChecker) Check(ctx context.Context, connectionString string) error {
+	address, u, err := parseAddress(connectionString, "6379")
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if username := u.User.Username(); username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := redisCommand(conn, reader, args...); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	reply, err := redisCommand(conn, reader, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "+PONG" {
+		return fmt.Errorf("unexpected reply %q to PING", reply)
+	}
+
+	return nil
+}
+
+// redisCommand sends a command encoded as a RESP array and returns the first
+// line of the reply. Error replies are returned as errors.
+func redisCommand(conn io.Writer, reader *bufio.Reader, args ...string) (string, error) {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := conn.Write([]byte(command.String())); err != nil {
+		return "", fmt.Errorf("sending %s: %w", args[0], err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("reading reply to %s: %w", args[0], err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, "-") {
+		return "", fmt.Errorf("%s", strings.TrimPrefix(line, "-"))
+	}
+
+	return line, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package image parses container image references, such as
+// ghcr.io/virtool/virtool:7.1.0, into the registry, repository, tag and digest
+// they name.
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is the registry of references that do not name one.
+const DefaultRegistry = "docker.io"
+
+var (
+	pathComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern    = regexp.MustCompile(`^\w[\w.-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// Registry is the host, and optional port, of the registry holding the
+	// image, for example ghcr.io.
+	Registry string
+
+	// Repository is the path of the image within the registry, for example
+	// virtool/virtool. Official images on Docker Hub are under library/.
+	Repository string
+
+	// Tag is the tag of the image, if any.
+	Tag string
+
+	// Digest is the content digest of the image, such as sha256:..., if any.
+	Digest string
+}
+
+// Parse parses an image reference. References without a registry are on
+// DefaultRegistry.
+func Parse(ref string) (Reference, error) {
+	var r Reference
+
+	name, digest, pinned := strings.Cut(ref, "@")
+	if pinned {
+		if !digestPattern.MatchString(digest) {
+			return Reference{}, fmt.Errorf("parsing image %q: invalid digest %q", ref, digest)
+		}
+		r.Digest = digest
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		r.Tag = name[i+1:]
+		name = name[:i]
+		if !tagPattern.MatchString(r.Tag) {
+			return Reference{}, fmt.Errorf("parsing image %q: invalid tag %q", ref, r.Tag)
+		}
+	}
+
+	r.Registry = DefaultRegistry
+	r.Repository = name
+	if host, path, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		r.Registry = host
+		r.Repository = path
+	}
+	if r.Registry == DefaultRegistry && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+
+	for _, component := range strings.Split(r.Repository, "/") {
+		if !pathComponent.MatchString(component) {
+			return Reference{}, fmt.Errorf("parsing image %q: invalid repository %q", ref, r.Repository)
+		}
+	}
+
+	return r, nil
+}
+
+// Name returns the registry and repository of the image, without its tag or
+// digest.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the fully qualified reference.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
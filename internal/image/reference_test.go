@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import "testing"
+
+const digest = "sha256:4c3f5e8c3a1f0f9b1d2a7e6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want Reference
+	}{
+		{"ghcr.io/virtool/virtool:7.1.0", Reference{Registry: "ghcr.io", Repository: "virtool/virtool", Tag: "7.1.0"}},
+		{"ghcr.io/virtool/virtool@" + digest, Reference{Registry: "ghcr.io", Repository: "virtool/virtool", Digest: digest}},
+		{"ghcr.io/virtool/ui:7.1.0@" + digest, Reference{Registry: "ghcr.io", Repository: "virtool/ui", Tag: "7.1.0", Digest: digest}},
+		{"localhost:5000/virtool:latest", Reference{Registry: "localhost:5000", Repository: "virtool", Tag: "latest"}},
+		{"localhost/virtool", Reference{Registry: "localhost", Repository: "virtool"}},
+		{"virtool/virtool:v7.1.0", Reference{Registry: DefaultRegistry, Repository: "virtool/virtool", Tag: "v7.1.0"}},
+		{"nginx", Reference{Registry: DefaultRegistry, Repository: "library/nginx"}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.ref)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.ref, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+
+	for _, ref := range []string{"", "ghcr.io/Virtool/virtool", "virtool:", "virtool:-1", "virtool@sha256:abc", "ghcr.io/virtool//virtool"} {
+		if _, err := Parse(ref); err == nil {
+			t.Errorf("expected %q to be rejected", ref)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"ghcr.io/virtool/virtool:7.1.0", "ghcr.io/virtool/virtool:7.1.0"},
+		{"ghcr.io/virtool/virtool:7.1.0@" + digest, "ghcr.io/virtool/virtool:7.1.0@" + digest},
+		{"nginx:1.25", "docker.io/library/nginx:1.25"},
+	}
+
+	for _, tt := range tests {
+		r, err := Parse(tt.ref)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.ref, err)
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
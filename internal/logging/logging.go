@@ -0,0 +1,51 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging defines the structured keys and levels of the operator's
+// logs and a sink that records log entries for tests.
+package logging
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Keys of the values attached to log entries.
+const (
+	KeyApp       = "app"
+	KeyNamespace = "namespace"
+	KeyComponent = "component"
+	KeyPhase     = "phase"
+	KeyUpgradeID = "upgradeID"
+	KeyBackup    = "backup"
+	KeyRestore   = "restore"
+)
+
+// DebugLevel is the verbosity of routine messages logged on every reconcile,
+// such as waiting for a rollout. Significant actions, such as starting an
+// upgrade or launching a job, are logged at the default level.
+const DebugLevel = 1
+
+// WithValues adds key-value pairs to the logger of ctx. It returns a context
+// holding the new logger, so that functions called with it log the values
+// too, and the logger itself.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) (context.Context, logr.Logger) {
+	logger := log.FromContext(ctx).WithValues(keysAndValues...)
+
+	return log.IntoContext(ctx, logger), logger
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	logger := recorder.Logger().WithName("controller").WithValues(KeyApp, "virtool")
+
+	logger.V(DebugLevel).Info("Starting reconciliation")
+	logger.WithName("jobs").WithValues(KeyComponent, "api").Info("Launched update job", KeyPhase, "pre-update")
+	logger.Error(errors.New("conflict"), "Unable to update status")
+
+	entries := recorder.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	if entries[0].Level != DebugLevel || entries[0].Values[KeyApp] != "virtool" {
+		t.Errorf("got %+v", entries[0])
+	}
+
+	launched := recorder.Find("Launched update job")
+	if len(launched) != 1 {
+		t.Fatalf("got %d launched entries, want 1", len(launched))
+	}
+	if e := launched[0]; e.Name != "controller.jobs" || e.Values[KeyComponent] != "api" || e.Values[KeyPhase] != "pre-update" {
+		t.Errorf("got %+v", e)
+	}
+
+	// Values added to a child logger do not leak into its parent.
+	if _, ok := entries[2].Values[KeyComponent]; ok || entries[2].Error == nil {
+		t.Errorf("got %+v", entries[2])
+	}
+}
+
+func TestWithValues(t *testing.T) {
+	recorder := NewRecorder()
+	ctx := log.IntoContext(context.Background(), recorder.Logger())
+
+	ctx, logger := WithValues(ctx, KeyApp, "virtool", KeyNamespace, "default")
+	logger.Info("Starting upgrade")
+	log.FromContext(ctx).WithValues(KeyUpgradeID, "abc").Info("Finished upgrade")
+
+	for _, entry := range recorder.Entries() {
+		if entry.Values[KeyApp] != "virtool" || entry.Values[KeyNamespace] != "default" {
+			t.Errorf("%s: got values %v", entry.Message, entry.Values)
+		}
+	}
+	if finished := recorder.Find("Finished upgrade"); len(finished) != 1 || finished[0].Values[KeyUpgradeID] != "abc" {
+		t.Errorf("got %+v", finished)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// Entry is a log entry captured by a Recorder.
+type Entry struct {
+	// Level is the verbosity of the entry. Errors are logged at level 0.
+	Level int
+
+	// Name is the name of the logger, with the names added by WithName
+	// joined by dots.
+	Name string
+
+	Message string
+
+	// Error is the error of an entry logged with Error.
+	Error error
+
+	// Values holds the key-value pairs of the logger and the entry.
+	Values map[string]interface{}
+}
+
+// Recorder is a logr.LogSink that records every entry, so that tests can
+// assert on the structured values of entries instead of their text. Loggers
+// derived from a Recorder with WithValues or WithName record to it too.
+type Recorder struct {
+	mu      *sync.Mutex
+	entries *[]Entry
+
+	name   string
+	values []interface{}
+}
+
+// NewRecorder returns a Recorder with no entries.
+func NewRecorder() *Recorder {
+	return &Recorder{mu: &sync.Mutex{}, entries: &[]Entry{}}
+}
+
+// Logger returns a logger writing to the recorder.
+func (r *Recorder) Logger() logr.Logger {
+	return logr.New(r)
+}
+
+// Entries returns the entries recorded so far.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Entry(nil), *r.entries...)
+}
+
+// Find returns the entries with message msg.
+func (r *Recorder) Find(msg string) []Entry {
+	var found []Entry
+	for _, entry := range r.Entries() {
+		if entry.Message == msg {
+			found = append(found, entry)
+		}
+	}
+
+	return found
+}
+
+// Init implements logr.LogSink.
+func (r *Recorder) Init(logr.RuntimeInfo) {}
+
+// Enabled implements logr.LogSink. Entries of every level are recorded.
+func (r *Recorder) Enabled(int) bool {
+	return true
+}
+
+// Info implements logr.LogSink.
+func (r *Recorder) Info(level int, msg string, keysAndValues ...interface{}) {
+	r.record(level, msg, nil, keysAndValues)
+}
+
+// Error implements logr.LogSink.
+func (r *Recorder) Error(err error, msg string, keysAndValues ...interface{}) {
+	r.record(0, msg, err, keysAndValues)
+}
+
+// WithValues implements logr.LogSink.
+func (r *Recorder) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	child := *r
+	child.values = append(append([]interface{}(nil), r.values...), keysAndValues...)
+
+	return &child
+}
+
+// WithName implements logr.LogSink.
+func (r *Recorder) WithName(name string) logr.LogSink {
+	child := *r
+	child.name = strings.TrimPrefix(r.name+"."+name, ".")
+
+	return &child
+}
+
+func (r *Recorder) record(level int, msg string, err error, keysAndValues []interface{}) {
+	values := map[string]interface{}{}
+	for _, kvs := range [][]interface{}{r.values, keysAndValues} {
+		for i := 0; i+1 < len(kvs); i += 2 {
+			if key, ok := kvs[i].(string); ok {
+				values[key] = kvs[i+1]
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	*r.entries = append(*r.entries, Entry{
+		Level:   level,
+		Name:    r.name,
+		Message: msg,
+		Error:   err,
+		Values:  values,
+	})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the operator's Prometheus metrics. They are
+// registered with the controller-runtime registry and served alongside the
+// default controller metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Label names shared by the metrics.
+const (
+	LabelNamespace      = "namespace"
+	LabelApp            = "app"
+	LabelComponent      = "component"
+	LabelPhase          = "phase"
+	LabelOutcome        = "outcome"
+	LabelVersion        = "version"
+	LabelCurrentVersion = "current_version"
+	LabelDesiredVersion = "desired_version"
+)
+
+// Outcomes of an upgrade.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+var (
+	// AppInfo is always 1 and carries the current and desired version of
+	// each app as labels.
+	AppInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "virtool_operator_app_info",
+		Help: "Current and desired Virtool version of each app. Always 1.",
+	}, []string{LabelNamespace, LabelApp, LabelCurrentVersion, LabelDesiredVersion})
+
+	// AppUpgrading is 1 while an app is being upgraded.
+	AppUpgrading = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "virtool_operator_app_upgrading",
+		Help: "Whether an upgrade of the app is in progress.",
+	}, []string{LabelNamespace, LabelApp})
+
+	// UpgradeDuration observes how long each version change took.
+	UpgradeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "virtool_operator_upgrade_duration_seconds",
+		Help:    "Time taken to move an app from one version to the next, including backups and update jobs.",
+		Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{LabelNamespace, LabelApp})
+
+	// UpgradesTotal counts finished and failed version changes.
+	UpgradesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "virtool_operator_upgrades_total",
+		Help: "Version changes by outcome. Failed upgrades are blocked by a failed backup or update job.",
+	}, []string{LabelNamespace, LabelApp, LabelOutcome})
+
+	// RollbacksTotal counts upgrades abandoned by setting the desired version
+	// back to the current version.
+	RollbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "virtool_operator_rollbacks_total",
+		Help: "Upgrades abandoned before they finished by returning to the current version.",
+	}, []string{LabelNamespace, LabelApp})
+
+	// UpdateJobDuration observes how long successful pre- and post-update jobs
+	// ran.
+	UpdateJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "virtool_operator_update_job_duration_seconds",
+		Help:    "Run time of successful pre-update and post-update jobs.",
+		Buckets: prometheus.ExponentialBuckets(5, 2, 12),
+	}, []string{LabelNamespace, LabelApp, LabelComponent, LabelPhase})
+
+	// ComponentReadyReplicas is the number of ready replicas of each component.
+	ComponentReadyReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "virtool_operator_component_ready_replicas",
+		Help: "Ready replicas of each component.",
+	}, []string{LabelNamespace, LabelApp, LabelComponent})
+
+	// ComponentReady is 1 when every replica of a component is updated and
+	// ready.
+	ComponentReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "virtool_operator_component_ready",
+		Help: "Whether every replica of a component is updated and ready.",
+	}, []string{LabelNamespace, LabelApp, LabelComponent})
+
+	// ComponentInfo is always 1 and carries the version each component is
+	// running as a label.
+	ComponentInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "virtool_operator_component_info",
+		Help: "Version each component is running. Always 1.",
+	}, []string{LabelNamespace, LabelApp, LabelComponent, LabelVersion})
+)
+
+// appVecs are the metrics with a series per app that are removed when the
+// app is deleted.
+var appVecs = []*prometheus.MetricVec{
+	AppInfo.MetricVec,
+	AppUpgrading.MetricVec,
+	UpgradeDuration.MetricVec,
+	UpgradesTotal.MetricVec,
+	RollbacksTotal.MetricVec,
+	UpdateJobDuration.MetricVec,
+	ComponentReadyReplicas.MetricVec,
+	ComponentReady.MetricVec,
+	ComponentInfo.MetricVec,
+}
+
+// Collectors returns every metric defined by the operator.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		AppInfo,
+		AppUpgrading,
+		UpgradeDuration,
+		UpgradesTotal,
+		RollbacksTotal,
+		UpdateJobDuration,
+		ComponentReadyReplicas,
+		ComponentReady,
+		ComponentInfo,
+	}
+}
+
+func init() {
+	metrics.Registry.MustRegister(Collectors()...)
+}
+
+// AppLabels returns the labels selecting every series of an app.
+func AppLabels(namespace, app string) prometheus.Labels {
+	return prometheus.Labels{LabelNamespace: namespace, LabelApp: app}
+}
+
+// DeleteApp removes every series of an app.
+func DeleteApp(namespace, app string) {
+	for _, vec := range appVecs {
+		vec.DeletePartialMatch(AppLabels(namespace, app))
+	}
+}
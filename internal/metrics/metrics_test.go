@@ -0,0 +1,46 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeleteApp(t *testing.T) {
+	AppInfo.WithLabelValues("default", "virtool", "7.1.0", "7.2.0").Set(1)
+	UpgradesTotal.WithLabelValues("default", "virtool", OutcomeSucceeded).Inc()
+	ComponentReady.WithLabelValues("default", "virtool", "api").Set(1)
+	ComponentReady.WithLabelValues("other", "virtool", "api").Set(1)
+
+	DeleteApp("default", "virtool")
+
+	for _, c := range []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"app info", testutil.CollectAndCount(AppInfo), 0},
+		{"upgrades", testutil.CollectAndCount(UpgradesTotal), 0},
+		{"component ready", testutil.CollectAndCount(ComponentReady), 1},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %d series, want %d", c.name, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+// Dashboard is the subset of the Grafana dashboard model used by the
+// operator's dashboard.
+type Dashboard struct {
+	Title         string    `json:"title"`
+	UID           string    `json:"uid"`
+	Tags          []string  `json:"tags"`
+	Timezone      string    `json:"timezone"`
+	SchemaVersion int       `json:"schemaVersion"`
+	Refresh       string    `json:"refresh"`
+	Time          TimeRange `json:"time"`
+	Templating    struct {
+		List []Variable `json:"list"`
+	} `json:"templating"`
+	Panels []Panel `json:"panels"`
+}
+
+// TimeRange is the default time range of a dashboard.
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Variable is a dashboard template variable.
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      interface{} `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+}
+
+// Datasource references the Prometheus datasource selected by the
+// datasource variable.
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// Panel is a single dashboard panel.
+type Panel struct {
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Type        string      `json:"type"`
+	Datasource  *Datasource `json:"datasource"`
+	GridPos     GridPos     `json:"gridPos"`
+	Targets     []Target    `json:"targets"`
+	FieldConfig struct {
+		Defaults struct {
+			Unit string `json:"unit,omitempty"`
+		} `json:"defaults"`
+	} `json:"fieldConfig"`
+}
+
+// GridPos places a panel on the dashboard grid, which is 24 units wide.
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Target is a query of a panel.
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Format       string `json:"format,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+}
+
+// selector restricts a query to the apps selected on the dashboard.
+const selector = `{namespace=~"$namespace", app=~"$app"}`
+
+// promDatasource is the datasource selected by the datasource variable.
+var promDatasource = &Datasource{Type: "prometheus", UID: "${datasource}"}
+
+// panelSpec describes a panel before it is laid out.
+type panelSpec struct {
+	title       string
+	description string
+	kind        string
+	unit        string
+	width       int
+	targets     []Target
+}
+
+// panels returns the panels of the dashboard, in order.
+func panels() []panelSpec {
+	return []panelSpec{
+		{
+			title:       "Versions",
+			description: "Current and desired version of each app.",
+			kind:        "table",
+			width:       24,
+			targets: []Target{{
+				Expr:    `virtool_operator_app_info` + selector,
+				Format:  "table",
+				Instant: true,
+			}},
+		},
+		{
+			title:       "Upgrades in progress",
+			description: "Apps with an upgrade in progress.",
+			kind:        "timeseries",
+			width:       12,
+			targets: []Target{{
+				Expr:         `virtool_operator_app_upgrading` + selector,
+				LegendFormat: "{{namespace}}/{{app}}",
+			}},
+		},
+		{
+			title:       "Upgrade outcomes",
+			description: "Version changes that finished or failed.",
+			kind:        "timeseries",
+			width:       12,
+			targets: []Target{
+				{
+					Expr:         `sum by (namespace, app, outcome) (increase(virtool_operator_upgrades_total` + selector + `[1h]))`,
+					LegendFormat: "{{namespace}}/{{app}} {{outcome}}",
+				},
+				{
+					Expr:         `sum by (namespace, app) (increase(virtool_operator_rollbacks_total` + selector + `[1h]))`,
+					LegendFormat: "{{namespace}}/{{app}} rolled back",
+				},
+			},
+		},
+		{
+			title:       "Upgrade duration (p95)",
+			description: "Time taken by each version change, including backups and update jobs.",
+			kind:        "timeseries",
+			unit:        "s",
+			width:       12,
+			targets: []Target{{
+				Expr: `histogram_quantile(0.95, sum by (le, namespace, app) ` +
+					`(rate(virtool_operator_upgrade_duration_seconds_bucket` + selector + `[6h])))`,
+				LegendFormat: "{{namespace}}/{{app}}",
+			}},
+		},
+		{
+			title:       "Update job duration (p95)",
+			description: "Run time of successful pre-update and post-update jobs.",
+			kind:        "timeseries",
+			unit:        "s",
+			width:       12,
+			targets: []Target{{
+				Expr: `histogram_quantile(0.95, sum by (le, namespace, app, component, phase) ` +
+					`(rate(virtool_operator_update_job_duration_seconds_bucket` + selector + `[6h])))`,
+				LegendFormat: "{{namespace}}/{{app}} {{component}} {{phase}}",
+			}},
+		},
+		{
+			title:       "Ready replicas",
+			description: "Ready replicas of each component.",
+			kind:        "timeseries",
+			width:       12,
+			targets: []Target{{
+				Expr:         `virtool_operator_component_ready_replicas` + selector,
+				LegendFormat: "{{namespace}}/{{app}} {{component}}",
+			}},
+		},
+		{
+			title:       "Component versions",
+			description: "Version each component is running.",
+			kind:        "table",
+			width:       12,
+			targets: []Target{{
+				Expr:    `virtool_operator_component_info` + selector,
+				Format:  "table",
+				Instant: true,
+			}},
+		},
+	}
+}
+
+// GrafanaDashboard returns the Grafana dashboard for apps managed by the
+// operator.
+func GrafanaDashboard() *Dashboard {
+	dashboard := &Dashboard{
+		Title:         "Virtool Operator",
+		UID:           "virtool-operator",
+		Tags:          []string{"virtool", "kubernetes"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          TimeRange{From: "now-24h", To: "now"},
+	}
+
+	dashboard.Templating.List = []Variable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{
+			Name:       "namespace",
+			Label:      "Namespace",
+			Type:       "query",
+			Query:      "label_values(virtool_operator_app_info, namespace)",
+			Datasource: promDatasource,
+			Refresh:    2,
+			IncludeAll: true,
+			Multi:      true,
+		},
+		{
+			Name:       "app",
+			Label:      "App",
+			Type:       "query",
+			Query:      `label_values(virtool_operator_app_info{namespace=~"$namespace"}, app)`,
+			Datasource: promDatasource,
+			Refresh:    2,
+			IncludeAll: true,
+			Multi:      true,
+		},
+	}
+
+	x, y, rowHeight := 0, 0, 8
+	for i, spec := range panels() {
+		if x+spec.width > 24 {
+			x, y = 0, y+rowHeight
+		}
+
+		panel := Panel{
+			ID:          i + 1,
+			Title:       spec.title,
+			Description: spec.description,
+			Type:        spec.kind,
+			Datasource:  promDatasource,
+			GridPos:     GridPos{H: rowHeight, W: spec.width, X: x, Y: y},
+		}
+		panel.FieldConfig.Defaults.Unit = spec.unit
+		for j, target := range spec.targets {
+			target.RefID = string(rune('A' + j))
+			panel.Targets = append(panel.Targets, target)
+		}
+
+		dashboard.Panels = append(dashboard.Panels, panel)
+		x += spec.width
+	}
+
+	return dashboard
+}
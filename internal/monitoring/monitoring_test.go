@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bryce-davidson/virtool-operator/internal/metrics"
+)
+
+var (
+	fqNamePattern     = regexp.MustCompile(`fqName: "([^"]+)"`)
+	metricNamePattern = regexp.MustCompile(`virtool_operator_[a-z_]+`)
+)
+
+// exportedMetrics returns the names of the series exported by the operator,
+// including the series of each histogram.
+func exportedMetrics(t *testing.T) map[string]bool {
+	t.Helper()
+
+	names := map[string]bool{}
+	for _, collector := range metrics.Collectors() {
+		ch := make(chan *prometheus.Desc, 1)
+		go func() {
+			collector.Describe(ch)
+			close(ch)
+		}()
+
+		for desc := range ch {
+			match := fqNamePattern.FindStringSubmatch(desc.String())
+			if match == nil {
+				t.Fatalf("no name in %s", desc)
+			}
+			names[match[1]] = true
+
+			if _, ok := collector.(*prometheus.HistogramVec); ok {
+				for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+					names[match[1]+suffix] = true
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+func checkExpr(t *testing.T, exported map[string]bool, name, expr string) {
+	t.Helper()
+
+	referenced := metricNamePattern.FindAllString(expr, -1)
+	if len(referenced) == 0 {
+		t.Errorf("%s: %q does not reference an operator metric", name, expr)
+	}
+	for _, metric := range referenced {
+		if !exported[metric] {
+			t.Errorf("%s: %s is not exported by the operator", name, metric)
+		}
+	}
+}
+
+func TestAlertsReferenceExportedMetrics(t *testing.T) {
+	exported := exportedMetrics(t)
+
+	for _, rule := range Alerts() {
+		checkExpr(t, exported, rule.Alert, rule.Expr)
+
+		if rule.Labels["severity"] == "" {
+			t.Errorf("%s: no severity", rule.Alert)
+		}
+		if rule.Annotations["summary"] == "" || rule.Annotations["description"] == "" {
+			t.Errorf("%s: missing summary or description", rule.Alert)
+		}
+	}
+}
+
+func TestDashboardReferencesExportedMetrics(t *testing.T) {
+	exported := exportedMetrics(t)
+	dashboard := GrafanaDashboard()
+
+	for _, variable := range dashboard.Templating.List {
+		if query, ok := variable.Query.(string); ok && strings.HasPrefix(query, "label_values") {
+			checkExpr(t, exported, "variable "+variable.Name, query)
+		}
+	}
+
+	for _, panel := range dashboard.Panels {
+		if len(panel.Targets) == 0 {
+			t.Errorf("%s: no queries", panel.Title)
+		}
+		for _, target := range panel.Targets {
+			checkExpr(t, exported, panel.Title, target.Expr)
+		}
+		if panel.GridPos.X+panel.GridPos.W > 24 {
+			t.Errorf("%s: does not fit the grid", panel.Title)
+		}
+	}
+}
+
+// TestGeneratedFiles fails if the files under config/ were not regenerated
+// after a change to the alerts or dashboard.
+func TestGeneratedFiles(t *testing.T) {
+	files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range files {
+		got, err := os.ReadFile(filepath.Join("..", "..", "config", path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("config/%s is out of date, run make monitoring", path)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Paths of the generated files, relative to the config directory.
+const (
+	RulesPath     = "prometheus/rules.yaml"
+	DashboardPath = "grafana/virtool-operator.json"
+)
+
+const generatedHeader = "# Code generated by hack/gen-monitoring. DO NOT EDIT.\n"
+
+// RenderRules returns the PrometheusRule manifest.
+func RenderRules() ([]byte, error) {
+	data, err := yaml.Marshal(Rules())
+	if err != nil {
+		return nil, fmt.Errorf("rendering alerts: %w", err)
+	}
+
+	return append([]byte(generatedHeader), data...), nil
+}
+
+// RenderDashboard returns the Grafana dashboard JSON.
+func RenderDashboard() ([]byte, error) {
+	data, err := json.MarshalIndent(GrafanaDashboard(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("rendering dashboard: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Files returns the generated files keyed by their path relative to the
+// config directory.
+func Files() (map[string][]byte, error) {
+	rules, err := RenderRules()
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard, err := RenderDashboard()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{RulesPath: rules, DashboardPath: dashboard}, nil
+}
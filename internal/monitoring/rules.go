@@ -0,0 +1,142 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package monitoring generates the Prometheus alerts and Grafana dashboard
+// shipped with the operator from the metrics it exports. Run
+// hack/gen-monitoring to write them under config/.
+package monitoring
+
+// PrometheusRule is the subset of the prometheus-operator PrometheusRule
+// resource used by the operator's alerts.
+type PrometheusRule struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Metadata   Metadata           `json:"metadata"`
+	Spec       PrometheusRuleSpec `json:"spec"`
+}
+
+// Metadata is the metadata of a generated resource.
+type Metadata struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// PrometheusRuleSpec holds the rule groups of a PrometheusRule.
+type PrometheusRuleSpec struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// RuleGroup is a named group of alerting rules.
+type RuleGroup struct {
+	Name  string `json:"name"`
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is a single alerting rule.
+type Rule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// notUpgrading matches apps that have no upgrade in progress.
+const notUpgrading = `on (namespace, app) (max by (namespace, app) (virtool_operator_app_upgrading) == 0)`
+
+// Alerts returns the alerting rules for apps managed by the operator.
+func Alerts() []Rule {
+	return []Rule{
+		{
+			Alert: "VirtoolUpgradeStuck",
+			Expr:  `max by (namespace, app) (virtool_operator_app_upgrading) == 1`,
+			For:   "2h",
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Virtool upgrade is not making progress",
+				"description": "The upgrade of {{ $labels.namespace }}/{{ $labels.app }} has been in progress " +
+					"for more than two hours. Check the Upgrading condition and events of the VirtoolApp.",
+			},
+		},
+		{
+			Alert: "VirtoolComponentDegraded",
+			Expr: `min by (namespace, app, component) (virtool_operator_component_ready) == 0 and ` +
+				notUpgrading,
+			For: "15m",
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Virtool component is not ready",
+				"description": "Component {{ $labels.component }} of {{ $labels.namespace }}/{{ $labels.app }} " +
+					"has had replicas that are not ready for 15 minutes outside of an upgrade.",
+			},
+		},
+		{
+			Alert: "VirtoolMigrationFailed",
+			Expr: `sum by (namespace, app) ` +
+				`(increase(virtool_operator_upgrades_total{outcome="failed"}[30m])) > 0`,
+			Labels: map[string]string{
+				"severity": "critical",
+			},
+			Annotations: map[string]string{
+				"summary": "Virtool upgrade failed",
+				"description": "An update job or backup of {{ $labels.namespace }}/{{ $labels.app }} failed and " +
+					"the upgrade is blocked until the failed Job is removed or the version is changed.",
+			},
+		},
+		{
+			Alert: "VirtoolVersionDrift",
+			Expr: `count by (namespace, app) ` +
+				`(count by (namespace, app, version) (virtool_operator_component_info)) > 1 and ` + notUpgrading,
+			For: "30m",
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Virtool components are running different versions",
+				"description": "The components of {{ $labels.namespace }}/{{ $labels.app }} have been running " +
+					"more than one version for 30 minutes outside of an upgrade.",
+			},
+		},
+	}
+}
+
+// Rules returns the PrometheusRule holding the operator's alerts.
+func Rules() *PrometheusRule {
+	return &PrometheusRule{
+		APIVersion: "monitoring.coreos.com/v1",
+		Kind:       "PrometheusRule",
+		Metadata: Metadata{
+			Name:      "virtool-alerts",
+			Namespace: "system",
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "prometheusrule",
+				"app.kubernetes.io/instance":   "virtool-alerts",
+				"app.kubernetes.io/component":  "metrics",
+				"app.kubernetes.io/created-by": "virtool-operator",
+				"app.kubernetes.io/part-of":    "virtool-operator",
+				"app.kubernetes.io/managed-by": "kustomize",
+			},
+		},
+		Spec: PrometheusRuleSpec{
+			Groups: []RuleGroup{{Name: "virtool.rules", Rules: Alerts()}},
+		},
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultGitHubURL is the base URL of the GitHub API.
+const DefaultGitHubURL = "https://api.github.com"
+
+// GitHub lists the releases of a GitHub repository. Drafts and prereleases
+// are skipped.
+type GitHub struct {
+	// URL is the base URL of the GitHub API. DefaultGitHubURL is used when it
+	// is empty.
+	URL string
+
+	// Repository is the repository in owner/name form.
+	Repository string
+
+	// Client is used for requests. A client bounded by DefaultTimeout is used
+	// when it is nil.
+	Client *http.Client
+}
+
+type githubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// Versions returns the tags of the repository's most recent releases.
+func (g *GitHub) Versions(ctx context.Context) ([]string, error) {
+	base := g.URL
+	if base == "" {
+		base = DefaultGitHubURL
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", strings.TrimSuffix(base, "/"), g.Repository)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := httpClient(g.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing releases of %s: %w", g.Repository, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing releases of %s: unexpected status %s", g.Repository, resp.Status)
+	}
+
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("decoding releases of %s: %w", g.Repository, err)
+	}
+
+	versions := make([]string, 0, len(releases))
+	for _, release := range releases {
+		if release.Draft || release.Prerelease {
+			continue
+		}
+		versions = append(versions, release.TagName)
+	}
+
+	return versions, nil
+}
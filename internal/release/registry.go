@@ -0,0 +1,213 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Registry lists the tags of an image repository in a container registry
+// implementing the OCI distribution API and resolves them to digests.
+// Anonymous bearer tokens are requested when the registry asks for them, as
+// ghcr.io and Docker Hub do.
+type Registry struct {
+	// URL is the base URL of the registry, for example https://ghcr.io.
+	URL string
+
+	// Repository is the repository within the registry, for example
+	// virtool/virtool.
+	Repository string
+
+	// Client is used for requests. A client bounded by DefaultTimeout is used
+	// when it is nil.
+	Client *http.Client
+}
+
+type tagList struct {
+	Tags []string `json:"tags"`
+}
+
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// manifestMediaTypes are the manifest types accepted when resolving a tag,
+// so that the registry returns the digest of a multi-platform index as it is
+// rather than of a manifest converted for a single platform.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Versions returns the tags of the repository.
+func (r *Registry) Versions(ctx context.Context) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/tags/list?n=1000", strings.TrimSuffix(r.URL, "/"), r.Repository)
+
+	resp, err := r.do(ctx, http.MethodGet, endpoint, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", r.Repository, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing tags of %s: unexpected status %s", r.Repository, resp.Status)
+	}
+
+	var tags tagList
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("decoding tags of %s: %w", r.Repository, err)
+	}
+
+	return tags.Tags, nil
+}
+
+// Digest returns the digest of the manifest tag points to in the repository.
+func (r *Registry) Digest(ctx context.Context, tag string) (string, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/manifests/%s", strings.TrimSuffix(r.URL, "/"), r.Repository, tag)
+
+	resp, err := r.do(ctx, http.MethodHead, endpoint, strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return "", fmt.Errorf("resolving %s:%s: %w", r.Repository, tag, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving %s:%s: unexpected status %s", r.Repository, tag, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("resolving %s:%s: registry did not return a digest", r.Repository, tag)
+	}
+
+	return digest, nil
+}
+
+// do sends a request to the registry, authenticating with an anonymous token
+// if the registry asks for one.
+func (r *Registry) do(ctx context.Context, method, endpoint, accept string) (*http.Response, error) {
+	resp, err := r.send(ctx, method, endpoint, accept, "")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	token, err := r.token(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.send(ctx, method, endpoint, accept, token)
+}
+
+func (r *Registry) send(ctx context.Context, method, endpoint, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return httpClient(r.Client).Do(req)
+}
+
+// token requests an anonymous pull token as described by a Bearer
+// WWW-Authenticate challenge.
+func (r *Registry) token(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry requires unsupported authentication")
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + r.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient(r.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting registry token: unexpected status %s", resp.Status)
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding registry token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+
+	return token.AccessToken, nil
+}
+
+// parseBearerChallenge parses the parameters of a Bearer WWW-Authenticate
+// header such as: Bearer realm="https://ghcr.io/token",service="ghcr.io".
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	scheme, rest, ok := strings.Cut(challenge, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for rest != "" {
+		var key, value string
+
+		key, rest, ok = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if !ok {
+			break
+		}
+
+		if strings.HasPrefix(rest, "\"") {
+			value, rest, _ = strings.Cut(rest[1:], "\"")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	return params, true
+}
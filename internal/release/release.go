@@ -0,0 +1,94 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package release finds the Virtool versions published to a release source
+// such as GitHub releases or a container registry.
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// DefaultTimeout bounds how long a single request to a release source may
+// take.
+const DefaultTimeout = 30 * time.Second
+
+// Source lists the versions published by a release source.
+type Source interface {
+	Versions(ctx context.Context) ([]string, error)
+}
+
+// Latest returns the newest version published by source that satisfies the
+// constraint, such as "~7.2", or an empty string if none does. Versions that
+// are not valid semantic versions are ignored. Any "v" prefix, as on GitHub
+// release tags, is removed so that the version matches the image tags and the
+// compatibility manifest.
+func Latest(ctx context.Context, source Source, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("parsing version constraint %q: %w", constraint, err)
+	}
+
+	versions, err := source.Versions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *semver.Version
+	var published string
+
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+			published = version
+		}
+	}
+
+	return strings.TrimPrefix(published, "v"), nil
+}
+
+// Newer reports whether version a is newer than version b. It is false if
+// either is not a valid semantic version.
+func Newer(a, b string) bool {
+	va, err := semver.NewVersion(a)
+	if err != nil {
+		return false
+	}
+	vb, err := semver.NewVersion(b)
+	if err != nil {
+		return false
+	}
+	return va.GreaterThan(vb)
+}
+
+// httpClient returns client, or a client bounded by DefaultTimeout if it is
+// nil.
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: DefaultTimeout}
+}
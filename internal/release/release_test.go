@@ -0,0 +1,181 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/virtool/virtool/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "7.3.0-beta.1", "prerelease": true},
+			{"tag_name": "7.2.4", "draft": true},
+			{"tag_name": "7.2.3"},
+			{"tag_name": "v7.2.1"},
+			{"tag_name": "7.1.9"}
+		]`)
+	}))
+	defer server.Close()
+
+	source := &GitHub{URL: server.URL, Repository: "virtool/virtool"}
+
+	versions, err := source.Versions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[7.2.3 v7.2.1 7.1.9]" {
+		t.Errorf("unexpected versions %v", versions)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:virtool/virtool:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+		case "/v2/virtool/virtool/tags/list":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:virtool/virtool:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"name": "virtool/virtool", "tags": ["latest", "7.2.0", "7.2.2"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := &Registry{URL: server.URL, Repository: "virtool/virtool"}
+
+	versions, err := source.Versions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[latest 7.2.0 7.2.2]" {
+		t.Errorf("unexpected versions %v", versions)
+	}
+}
+
+func TestRegistryDigest(t *testing.T) {
+	const digest = "sha256:4c3f5e8c3a1f0f9b1d2a7e6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/v2/virtool/virtool/manifests/7.2.0" {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			http.Error(w, "index not accepted", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+
+	registry := &Registry{URL: server.URL, Repository: "virtool/virtool"}
+
+	got, err := registry.Digest(context.Background(), "7.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != digest {
+		t.Errorf("got digest %q, want %q", got, digest)
+	}
+
+	if _, err := registry.Digest(context.Background(), "7.9.9"); err == nil {
+		t.Error("expected a missing tag to be an error")
+	}
+}
+
+type staticSource []string
+
+func (s staticSource) Versions(context.Context) ([]string, error) {
+	return s, nil
+}
+
+func TestLatest(t *testing.T) {
+	source := staticSource{"latest", "7.1.9", "v7.2.1", "7.2.10", "7.2.3", "7.3.0", "7.2.11-rc.1"}
+
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"~7.2", "7.2.10"},
+		{"^7", "7.3.0"},
+		{"~7.1", "7.1.9"},
+		{"~8.0", ""},
+		{"7.2.1", "7.2.1"},
+	}
+
+	for _, tt := range tests {
+		got, err := Latest(context.Background(), source, tt.constraint)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.constraint, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+
+	if _, err := Latest(context.Background(), source, "not a constraint"); err == nil {
+		t.Error("expected an invalid constraint to be rejected")
+	}
+}
+
+func TestLatestGitHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v7.1.0"}, {"tag_name": "v7.0.2"}]`)
+	}))
+	defer server.Close()
+
+	got, err := Latest(context.Background(), &GitHub{URL: server.URL, Repository: "virtool/virtool"}, "^7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "7.1.0" {
+		t.Errorf("got %q, want 7.1.0", got)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	if !Newer("7.2.10", "7.2.9") {
+		t.Error("expected 7.2.10 to be newer than 7.2.9")
+	}
+	if Newer("v7.2.1", "7.2.1") {
+		t.Error("expected v7.2.1 and 7.2.1 to be the same version")
+	}
+	if Newer("latest", "7.2.1") {
+		t.Error("expected invalid versions never to be newer")
+	}
+}
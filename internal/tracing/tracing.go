@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing sets up OpenTelemetry tracing of the operator and exports
+// spans to an OTLP collector.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// ServiceName identifies the operator in exported traces.
+const ServiceName = "virtool-operator"
+
+// Options configures the export of spans.
+type Options struct {
+	// Endpoint is the host:port of the OTLP/HTTP receiver of a collector, or a
+	// URL if the receiver is not served at the default /v1/traces path.
+	Endpoint string
+
+	// Insecure sends spans over plain HTTP instead of HTTPS.
+	Insecure bool
+
+	// SampleRatio is the fraction of traces that are recorded. Spans whose
+	// parent was sampled are always recorded so upgrades are traced whole.
+	SampleRatio float64
+
+	// Attributes are added to the resource describing the operator.
+	Attributes []attribute.KeyValue
+}
+
+// NewTracerProvider returns a tracer provider that exports spans in batches
+// to the collector at Options.Endpoint.
+func NewTracerProvider(opts Options) (*sdktrace.TracerProvider, error) {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is not between 0 and 1", opts.SampleRatio)
+	}
+
+	endpoint, err := tracesURL(opts.Endpoint, opts.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	exporterOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(endpoint.Path),
+	}
+	if endpoint.Scheme == "http" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		append([]attribute.KeyValue{semconv.ServiceName(ServiceName)}, opts.Attributes...)...))
+	if err != nil {
+		return nil, fmt.Errorf("building resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	), nil
+}
+
+// tracesURL returns the URL spans are posted to for an endpoint.
+func tracesURL(endpoint string, insecure bool) (*url.URL, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("no OTLP endpoint")
+	}
+
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		// A bare host:port does not parse as a URL with a host.
+		u, err = url.Parse(scheme + "://" + endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+		}
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	return u, nil
+}
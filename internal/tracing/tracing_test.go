@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		insecure bool
+		want     string
+		ok       bool
+	}{
+		{"otel-collector:4318", true, "http://otel-collector:4318/v1/traces", true},
+		{"otel-collector:4318", false, "https://otel-collector:4318/v1/traces", true},
+		{"https://collector.example.com/otlp/v1/traces", false, "https://collector.example.com/otlp/v1/traces", true},
+		{"http://collector.example.com:4318/", false, "http://collector.example.com:4318/v1/traces", true},
+		{"", true, "", false},
+	}
+
+	for _, tt := range tests {
+		u, err := tracesURL(tt.endpoint, tt.insecure)
+		var got string
+		if u != nil {
+			got = u.String()
+		}
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("tracesURL(%q, %t) = %q, %v; want %q, ok=%t", tt.endpoint, tt.insecure, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	if _, err := NewTracerProvider(Options{Endpoint: "localhost:4318", SampleRatio: 2}); err == nil {
+		t.Error("expected a sample ratio above 1 to be refused")
+	}
+
+	tp, err := NewTracerProvider(Options{Endpoint: "localhost:4318", Insecure: true, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewTracerProviderExports(t *testing.T) {
+	var mu sync.Mutex
+	var paths, contentTypes []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tp, err := NewTracerProvider(Options{Endpoint: server.URL + "/otlp/v1/traces", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := tp.Tracer("test").Start(context.Background(), "Reconcile")
+	span.End()
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) != 1 || paths[0] != "/otlp/v1/traces" || contentTypes[0] != "application/x-protobuf" {
+		t.Errorf("got requests to %v with content types %v", paths, contentTypes)
+	}
+}